@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/statusquonjc46/chirpy-http/internal/auth"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"net/http"
+	"time"
+)
+
+// soft deleted accounts can be restored for 30 days before the purge job removes them
+const deletionGracePeriod = 30 * 24 * time.Hour
+
+// accounts without a password re-authenticate by logging in again, the token must be this fresh
+const reauthWindow = 5 * time.Minute
+
+var errAccountDeleted = errors.New("account is pending deletion")
+
+// Deletes the authenticated user's account, hard delete cascades to chirps right away, soft delete can be restored during the grace period
+func (cfg *apiConfig) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+		Mode     string `json:"mode"`
+	}
+	type response struct {
+		Mode       string     `json:"mode"`
+		PurgeAfter *time.Time `json:"purge_after,omitempty"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unable to decode json request."}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal delete account decode error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+	if params.Mode == "" {
+		params.Mode = "soft"
+	}
+	if params.Mode != "soft" && params.Mode != "hard" {
+		rtn := &returnErrors{Error: "mode must be soft or hard"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal delete mode error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	user, err := cfg.database.GetUserByID(r.Context(), userID)
+	if err != nil || user.DeletedAt.Valid {
+		rtn := &returnErrors{Error: "User not found"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal user lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(404)
+		w.Write(dat)
+		return
+	}
+
+	//re-authentication: the password if the account has one, otherwise a freshly issued token
+	reauthed := false
+	if params.Password != "" {
+		reauthed = auth.CheckPasswordHash(user.HashedPassword, params.Password) == nil
+	} else if user.HashedPassword == "unset" {
+		issuedAt, err := auth.GetJWTIssuedAt(token, cfg.jwtSecret)
+		reauthed = err == nil && time.Since(issuedAt) <= reauthWindow
+	}
+	if !reauthed {
+		rtn := &returnErrors{Error: "Re-authentication required"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal re-authentication error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(403)
+		w.Write(dat)
+		return
+	}
+
+	rtn := response{Mode: params.Mode}
+	if params.Mode == "hard" {
+		//chirps, sessions and identities are removed by the ON DELETE CASCADE foreign keys
+		err = cfg.database.HardDeleteUser(r.Context(), user.ID)
+	} else {
+		purgeAfter := time.Now().UTC().Add(deletionGracePeriod)
+		rtn.PurgeAfter = &purgeAfter
+		err = cfg.softDeleteUser(r.Context(), user, purgeAfter)
+	}
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to delete account"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal delete account error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error deleting account: %s\n", err)
+		return
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling delete account response: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Marks the user deleted and logs them out everywhere in one transaction
+func (cfg *apiConfig) softDeleteUser(ctx context.Context, user database.User, purgeAfter time.Time) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	deleteParams := database.SoftDeleteUserParams{
+		ID:         user.ID,
+		PurgeAfter: sql.NullTime{Time: purgeAfter, Valid: true},
+	}
+	err = qtx.SoftDeleteUser(ctx, deleteParams)
+	if err != nil {
+		return err
+	}
+	err = qtx.RevokeAllSessionsForUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Restores a soft deleted account during the grace period, takes the same email and password as login
+func (cfg *apiConfig) restoreAccount(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unable to decode json POST request."}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal restore decode error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	getUser, err := cfg.database.UserandHashLookup(r.Context(), params.Email)
+	if err == nil {
+		err = auth.CheckPasswordHash(getUser.HashedPassword, params.Password)
+	}
+	if err != nil {
+		rtn := &returnErrors{Error: "Incorrect email or password"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal restore login error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	restored, err := cfg.database.RestoreUser(r.Context(), getUser.ID)
+	if err != nil {
+		rtn := &returnErrors{Error: "Account is not pending deletion"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal restore error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(409)
+		w.Write(dat)
+		return
+	}
+
+	ret := &User{
+		ID:          restored.ID,
+		CreatedAt:   restored.CreatedAt,
+		UpdatedAt:   restored.UpdatedAt,
+		Email:       restored.Email.String,
+		IsChirpyRed: restored.IsChirpyRed,
+	}
+	dat, err := json.Marshal(ret)
+	if err != nil {
+		fmt.Printf("Error marshalling restored user: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// BACKGROUND JOBS
+// finalizes soft deletions whose grace period has passed, runs until ctx is cancelled
+func (cfg *apiConfig) purgeDeletedUsersJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := cfg.database.PurgeDeletedUsers(ctx)
+		if err != nil {
+			fmt.Printf("Failed to purge deleted users: %s\n", err)
+		} else if purged > 0 {
+			fmt.Printf("Purged %d deleted users\n", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/pagination"
+	"net/http"
+	"time"
+)
+
+// entry in the authenticated user's blocked or muted list, Since is when they were blocked or muted
+type HiddenUser struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Since       time.Time `json:"since"`
+}
+
+// Blocks the user with the handle in the path. The two users stop seeing each other's chirps and can't follow, reply to,
+// like or mention each other, any follows between them are removed. Blocking someone twice is a no-op
+func (cfg *apiConfig) blockUser(w http.ResponseWriter, r *http.Request) {
+	cfg.hideUser(w, r, true)
+}
+
+// Mutes the user with the handle in the path, their chirps and notifications are left out of the caller's timelines
+// and notifications. The muted user can still see and interact with the caller. Muting someone twice is a no-op
+func (cfg *apiConfig) muteUser(w http.ResponseWriter, r *http.Request) {
+	cfg.hideUser(w, r, false)
+}
+
+// Unblocks the user with the handle in the path, follows removed by the block aren't restored
+func (cfg *apiConfig) unblockUser(w http.ResponseWriter, r *http.Request) {
+	cfg.unhideUser(w, r, true)
+}
+
+// Unmutes the user with the handle in the path
+func (cfg *apiConfig) unmuteUser(w http.ResponseWriter, r *http.Request) {
+	cfg.unhideUser(w, r, false)
+}
+
+// Lists the users the authenticated user has blocked, most recent first
+func (cfg *apiConfig) getBlocks(w http.ResponseWriter, r *http.Request) {
+	cfg.listHidden(w, r, true)
+}
+
+// Lists the users the authenticated user has muted, most recent first
+func (cfg *apiConfig) getMutes(w http.ResponseWriter, r *http.Request) {
+	cfg.listHidden(w, r, false)
+}
+
+// blocks and mutes share their lookups, blocked picks which one is applied
+func (cfg *apiConfig) hideUser(w http.ResponseWriter, r *http.Request, blocked bool) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	otherID, err := cfg.database.GetUserIDByHandle(r.Context(), r.PathValue("handle"))
+	if err != nil || otherID == userID {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for user"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "User not found"
+		} else if err == nil && blocked {
+			status = 400
+			rtn.Error = "You can't block yourself"
+		} else if err == nil {
+			status = 400
+			rtn.Error = "You can't mute yourself"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal block lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	if blocked {
+		err = cfg.block(r.Context(), userID, otherID)
+	} else {
+		_, err = cfg.database.MuteUser(r.Context(), database.MuteUserParams{MuterID: userID, MutedID: otherID})
+	}
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to mute user"}
+		if blocked {
+			rtn.Error = "Failed to block user"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal block error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error blocking or muting user: %s\n", err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// Records the block and removes the follows between the two users along with each one's chirps from the other's
+// timeline, so nothing is left that the block would have prevented
+func (cfg *apiConfig) block(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	added, err := qtx.BlockUser(ctx, database.BlockUserParams{BlockerID: blockerID, BlockedID: blockedID})
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return nil
+	}
+	err = qtx.RemoveFollowsBetween(ctx, database.RemoveFollowsBetweenParams{UserID: blockerID, OtherID: blockedID})
+	if err != nil {
+		return err
+	}
+	err = qtx.ClearTimelineAuthor(ctx, database.ClearTimelineAuthorParams{UserID: blockerID, AuthorID: blockedID})
+	if err != nil {
+		return err
+	}
+	err = qtx.ClearTimelineAuthor(ctx, database.ClearTimelineAuthorParams{UserID: blockedID, AuthorID: blockerID})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (cfg *apiConfig) unhideUser(w http.ResponseWriter, r *http.Request, blocked bool) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	otherID, err := cfg.database.GetUserIDByHandle(r.Context(), r.PathValue("handle"))
+	if err == nil && blocked {
+		_, err = cfg.database.UnblockUser(r.Context(), database.UnblockUserParams{BlockerID: userID, BlockedID: otherID})
+	} else if err == nil {
+		_, err = cfg.database.UnmuteUser(r.Context(), database.UnmuteUserParams{MuterID: userID, MutedID: otherID})
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to unmute user"}
+		if blocked {
+			rtn.Error = "Failed to unblock user"
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "User not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unblock error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// both lists share a row shape, blocked picks which one is read
+func (cfg *apiConfig) listHidden(w http.ResponseWriter, r *http.Request, blocked bool) {
+	type response struct {
+		Users      []HiddenUser `json:"users"`
+		NextCursor string       `json:"next_cursor,omitempty"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	limit, err := pageLimit(r, 50, 200)
+	if err != nil {
+		rtn := &returnErrors{Error: "limit must be a positive number"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal block list limit error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	listParams := database.GetBlockedUsersParams{UserID: userID, PageLimit: limit}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			rtn := &returnErrors{Error: "Invalid cursor"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal block list cursor error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+		listParams.CursorCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		listParams.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	var rows []database.GetBlockedUsersRow
+	if blocked {
+		rows, err = cfg.database.GetBlockedUsers(r.Context(), listParams)
+	} else {
+		var mutedRows []database.GetMutedUsersRow
+		mutedRows, err = cfg.database.GetMutedUsers(r.Context(), database.GetMutedUsersParams(listParams))
+		for _, row := range mutedRows {
+			rows = append(rows, database.GetBlockedUsersRow(row))
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error querying block list: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+
+	rtn := response{Users: make([]HiddenUser, 0, len(rows))}
+	for _, row := range rows {
+		rtn.Users = append(rtn.Users, HiddenUser{
+			ID:          row.ID,
+			Handle:      row.Handle.String,
+			DisplayName: row.DisplayName,
+			Bio:         row.Bio,
+			IsChirpyRed: row.IsChirpyRed,
+			Since:       row.CreatedAt,
+		})
+	}
+	if len(rows) == int(limit) {
+		last := rows[len(rows)-1]
+		rtn.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling block list: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Looks up a chirp the viewer is allowed to see, chirps by users the viewer blocked or was blocked by are reported as
+// sql.ErrNoRows like a missing one. viewerID is uuid.Nil for anonymous requests, they aren't blocked by anyone
+func (cfg *apiConfig) visibleChirp(ctx context.Context, chirpID, viewerID uuid.UUID) (database.Chirp, error) {
+	chirp, err := cfg.database.GetSpecificChirp(ctx, chirpID)
+	if err != nil || viewerID == uuid.Nil || !chirp.UserID.Valid {
+		return chirp, err
+	}
+	blocked, err := cfg.database.IsBlocked(ctx, database.IsBlockedParams{UserID: viewerID, OtherID: chirp.UserID.UUID})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if blocked {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+// Users hidden from the viewer by a block in either direction, nil for anonymous requests
+func (cfg *apiConfig) blockedUserIDs(ctx context.Context, viewerID uuid.UUID) (map[uuid.UUID]bool, error) {
+	if viewerID == uuid.Nil {
+		return nil, nil
+	}
+	ids, err := cfg.database.GetBlockedUserIDs(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	blocked := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		blocked[id] = true
+	}
+	return blocked, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/pagination"
+	"net/http"
+)
+
+// Saves a chirp to the authenticated user's bookmarks, bookmarking twice is a no-op
+func (cfg *apiConfig) bookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err == nil {
+		_, err = cfg.visibleChirp(r.Context(), chirpID, userID)
+	}
+	if err == nil {
+		_, err = cfg.database.BookmarkChirp(r.Context(), database.BookmarkChirpParams{UserID: userID, ChirpID: chirpID})
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to bookmark chirp"}
+		if errors.Is(err, sql.ErrNoRows) || chirpID == uuid.Nil {
+			status = 404
+			rtn.Error = "Chirp not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal bookmark error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// Removes a chirp from the authenticated user's bookmarks, removing one that isn't bookmarked is a no-op
+func (cfg *apiConfig) removeBookmark(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		rtn := &returnErrors{Error: "Invalid chirp ID"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal remove bookmark chirp ID error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	_, err = cfg.database.RemoveBookmark(r.Context(), database.RemoveBookmarkParams{UserID: userID, ChirpID: chirpID})
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to remove bookmark"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal remove bookmark error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error removing bookmark: %s\n", err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// Lists the authenticated user's bookmarked chirps, most recently bookmarked first
+func (cfg *apiConfig) getBookmarks(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Chirps     []Chirp `json:"chirps"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	limit, err := pageLimit(r, 20, 100)
+	if err != nil {
+		rtn := &returnErrors{Error: "limit must be a positive number"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal bookmarks limit error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	bookmarkParams := database.GetBookmarksParams{UserID: userID, PageLimit: limit}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			rtn := &returnErrors{Error: "Invalid cursor"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal bookmarks cursor error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+		bookmarkParams.CursorCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		bookmarkParams.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	rows, err := cfg.database.GetBookmarks(r.Context(), bookmarkParams)
+	if err != nil {
+		fmt.Printf("Error querying bookmarks: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	chirpRows := make([]database.Chirp, 0, len(rows))
+	for _, row := range rows {
+		chirpRows = append(chirpRows, database.Chirp{
+			ID:             row.ID,
+			CreatedAt:      row.CreatedAt,
+			UpdatedAt:      row.UpdatedAt,
+			Body:           row.Body,
+			UserID:         row.UserID,
+			SearchVector:   row.SearchVector,
+			InReplyToID:    row.InReplyToID,
+			ConversationID: row.ConversationID,
+			LikeCount:      row.LikeCount,
+			RechirpOfID:    row.RechirpOfID,
+			QuoteOfID:      row.QuoteOfID,
+			IsQuote:        row.IsQuote,
+		})
+	}
+	chirps, err := cfg.chirpResponses(r.Context(), chirpRows, userID)
+	if err != nil {
+		fmt.Printf("Error building bookmarked chirps: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	rtn := response{Chirps: chirps}
+	//pages follow bookmark order, so the cursor is when the last chirp was bookmarked rather than when it was posted
+	if len(rows) == int(limit) {
+		last := rows[len(rows)-1]
+		rtn.NextCursor = pagination.EncodeCursor(last.BookmarkedAt, last.ID)
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling bookmarks: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
@@ -0,0 +1,561 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"net/http"
+	"time"
+)
+
+var errInvalidDraft = errors.New("invalid draft")
+
+type Draft struct {
+	ID        uuid.UUID   `json:"id"`
+	Body      string      `json:"body"`
+	MediaIDs  []uuid.UUID `json:"media_ids"`
+	PublishAt *time.Time  `json:"publish_at"`
+	LastError string      `json:"last_error,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// body of POST /api/drafts and PUT /api/drafts/{draftID}, a publish_at schedules the draft and null unschedules it
+type draftParameters struct {
+	Body      string     `json:"body"`
+	MediaIDs  []string   `json:"media_ids"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// Saves a new draft for the authenticated user, drafts with a publish_at are published by the scheduler when it passes
+func (cfg *apiConfig) createDraft(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	params, err := cfg.draftParams(r, userID)
+	var row database.Draft
+	if err == nil {
+		row, err = cfg.database.CreateDraft(r.Context(), database.CreateDraftParams{
+			UserID:    userID,
+			Body:      params.Body,
+			MediaIds:  params.MediaIds,
+			PublishAt: params.PublishAt,
+		})
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to save draft"}
+		if errors.Is(err, errInvalidDraft) || errors.Is(err, errInvalidChirpMedia) {
+			status = 400
+			rtn.Error = err.Error()
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal create draft error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	dat, err := json.Marshal(draftResponse(row))
+	if err != nil {
+		fmt.Printf("Error marshalling draft: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(dat)
+}
+
+// Lists the authenticated user's drafts, scheduled ones included, most recently changed first
+func (cfg *apiConfig) getDrafts(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	rows, err := cfg.database.GetDrafts(r.Context(), userID)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to query DB for drafts"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal drafts error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		return
+	}
+
+	drafts := make([]Draft, 0, len(rows))
+	for _, row := range rows {
+		drafts = append(drafts, draftResponse(row))
+	}
+	dat, err := json.Marshal(drafts)
+	if err != nil {
+		fmt.Printf("Error marshalling drafts: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Returns one of the authenticated user's drafts, other users' drafts are reported as not found
+func (cfg *apiConfig) getDraft(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	row := database.Draft{}
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		err = sql.ErrNoRows
+	} else {
+		row, err = cfg.database.GetDraft(r.Context(), database.GetDraftParams{ID: draftID, UserID: userID})
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for draft"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "Draft not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal draft error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	dat, err := json.Marshal(draftResponse(row))
+	if err != nil {
+		fmt.Printf("Error marshalling draft: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Replaces the body, media and schedule of one of the authenticated user's drafts
+func (cfg *apiConfig) updateDraft(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	var row database.Draft
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		err = sql.ErrNoRows
+	}
+	params := database.UpdateDraftParams{}
+	if err == nil {
+		params, err = cfg.draftParams(r, userID)
+	}
+	if err == nil {
+		params.ID = draftID
+		params.UserID = userID
+		row, err = cfg.database.UpdateDraft(r.Context(), params)
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to save draft"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "Draft not found"
+		} else if errors.Is(err, errInvalidDraft) || errors.Is(err, errInvalidChirpMedia) {
+			status = 400
+			rtn.Error = err.Error()
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal update draft error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	dat, err := json.Marshal(draftResponse(row))
+	if err != nil {
+		fmt.Printf("Error marshalling draft: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Discards one of the authenticated user's drafts, a scheduled draft deleted before it's due is never published
+func (cfg *apiConfig) deleteDraft(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	var deleted int64
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err == nil {
+		deleted, err = cfg.database.DeleteDraft(r.Context(), database.DeleteDraftParams{ID: draftID, UserID: userID})
+		if err != nil {
+			rtn := &returnErrors{Error: "Failed to delete draft"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal delete draft error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(503)
+			w.Write(dat)
+			return
+		}
+	}
+	if deleted == 0 {
+		rtn := &returnErrors{Error: "Draft not found"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal draft not found error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(404)
+		w.Write(dat)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Publishes one of the authenticated user's drafts as a chirp right away, whether or not it's scheduled
+func (cfg *apiConfig) publishDraft(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	var row database.Chirp
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		err = sql.ErrNoRows
+	} else {
+		row, err = cfg.publishDraftNow(r.Context(), draftID, userID)
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to publish draft"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "Draft not found"
+		} else if errors.Is(err, errInvalidChirpMedia) {
+			status = 400
+			rtn.Error = err.Error()
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal publish draft error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	chirp, err := cfg.announceChirp(r.Context(), row)
+	if err != nil {
+		fmt.Printf("Error building chirp response: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	dat, err := json.Marshal(chirp)
+	if err != nil {
+		fmt.Printf("Error marshalling json for chirp: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) publishDraftNow(ctx context.Context, draftID, userID uuid.UUID) (database.Chirp, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	draft, err := qtx.GetDraftForUpdate(ctx, database.GetDraftForUpdateParams{ID: draftID, UserID: userID})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	chirp, err := cfg.chirpFromDraft(ctx, qtx, draft)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return chirp, tx.Commit()
+}
+
+// Publishes due scheduled drafts until ctx ends. Each draft is claimed, published and deleted in one transaction and
+// claims skip locked rows, so a draft is published exactly once however many instances run this
+func (cfg *apiConfig) publishScheduledChirpsJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for {
+			published, err := cfg.publishDueDraft(ctx)
+			if err != nil {
+				fmt.Printf("Failed to publish scheduled chirp: %s\n", err)
+				break
+			}
+			if !published {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reports false once no drafts are due. A draft whose media can no longer be attached is unscheduled with the reason
+// kept in last_error so its author can fix it and schedule it again
+func (cfg *apiConfig) publishDueDraft(ctx context.Context) (bool, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	draft, err := qtx.ClaimDueDraft(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	chirp, err := cfg.chirpFromDraft(ctx, qtx, draft)
+	if errors.Is(err, errInvalidChirpMedia) {
+		err = qtx.UnscheduleDraft(ctx, database.UnscheduleDraftParams{
+			ID:        draft.ID,
+			LastError: sql.NullString{String: err.Error(), Valid: true},
+		})
+		if err != nil {
+			return false, err
+		}
+		return true, tx.Commit()
+	}
+	if err != nil {
+		return false, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = cfg.announceChirp(ctx, chirp)
+	if err != nil {
+		fmt.Printf("Error announcing scheduled chirp %s: %s\n", chirp.ID, err)
+	}
+	return true, nil
+}
+
+// Turns a locked draft into a chirp and deletes the draft inside qtx's transaction. The media was checked when the
+// draft was saved but may have been attached to another chirp since, so it's checked again here
+func (cfg *apiConfig) chirpFromDraft(ctx context.Context, qtx *database.Queries, draft database.Draft) (database.Chirp, error) {
+	if len(draft.MediaIds) > 0 {
+		count, err := qtx.CountAttachableMedia(ctx, database.CountAttachableMediaParams{
+			UserID:   draft.UserID,
+			MediaIds: draft.MediaIds,
+		})
+		if err != nil {
+			return database.Chirp{}, err
+		}
+		if count != int64(len(draft.MediaIds)) {
+			return database.Chirp{}, fmt.Errorf("%w: media must be your own uploads that aren't attached to another chirp", errInvalidChirpMedia)
+		}
+	}
+
+	chirp, err := cfg.insertChirp(ctx, qtx, database.AddChirpParams{
+		Body:   censorChirp(draft.Body),
+		UserID: uuid.NullUUID{UUID: draft.UserID, Valid: true},
+	}, draft.MediaIds, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	_, err = qtx.DeleteDraft(ctx, database.DeleteDraftParams{ID: draft.ID, UserID: draft.UserID})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return chirp, nil
+}
+
+// Decodes and validates a draft body, drafts are held to the chirp length limit when they're saved.
+// Banned words are left alone until the draft is published
+func (cfg *apiConfig) draftParams(r *http.Request, userID uuid.UUID) (database.UpdateDraftParams, error) {
+	params := draftParameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		return database.UpdateDraftParams{}, fmt.Errorf("%w: request body must be JSON", errInvalidDraft)
+	}
+	if len(params.Body) > maxChirpLength {
+		return database.UpdateDraftParams{}, fmt.Errorf("%w: chirp is too long", errInvalidDraft)
+	}
+	publishAt := sql.NullTime{}
+	if params.PublishAt != nil {
+		if !params.PublishAt.After(time.Now()) {
+			return database.UpdateDraftParams{}, fmt.Errorf("%w: publish_at must be in the future", errInvalidDraft)
+		}
+		//the column has no time zone, store UTC so any offset the client sent is honoured
+		publishAt = sql.NullTime{Time: params.PublishAt.UTC(), Valid: true}
+	}
+	mediaIDs, err := cfg.chirpMediaIDs(r, userID, params.MediaIDs)
+	if err != nil {
+		return database.UpdateDraftParams{}, err
+	}
+	if mediaIDs == nil {
+		mediaIDs = []uuid.UUID{}
+	}
+	return database.UpdateDraftParams{
+		Body:      params.Body,
+		MediaIds:  mediaIDs,
+		PublishAt: publishAt,
+	}, nil
+}
+
+func draftResponse(row database.Draft) Draft {
+	draft := Draft{
+		ID:        row.ID,
+		Body:      row.Body,
+		MediaIDs:  row.MediaIds,
+		LastError: row.LastError.String,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	}
+	if draft.MediaIDs == nil {
+		draft.MediaIDs = []uuid.UUID{}
+	}
+	if row.PublishAt.Valid {
+		draft.PublishAt = &row.PublishAt.Time
+	}
+	return draft
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/statusquonjc46/chirpy-http/internal/broker"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"time"
+)
+
+const eventsChannel = "chirpy_events"
+const eventsCatchUpBatch = 500
+const eventsListenerMinReconnect = time.Second
+const eventsListenerMaxReconnect = time.Minute
+
+// pq pings the connection this often when no notifications arrive, also how often we check the table for missed events
+const eventsListenerPing = 90 * time.Second
+
+// how long events stay in the table for listeners catching up after a disconnect
+const eventsRetention = 24 * time.Hour
+
+// how long a skipped id is waited for before it's taken to be a rolled back insert
+const eventsGapTimeout = 5 * time.Minute
+
+// a jump in ids bigger than this isn't tracked id by id, only the ids right below the new event are
+const eventsMaxGap = 1000
+
+// What the listener has published. Ids are taken when an event is inserted but the event only becomes readable when
+// its transaction commits, so an id can turn up after higher ones were already published. Ids skipped over are kept
+// in gaps and read again until they turn up or eventsGapTimeout passes
+type eventCursor struct {
+	lastID int64
+	gaps   map[int64]time.Time
+}
+
+// Writes an event to the events table, the insert trigger notifies every instance's listener which hands it to its broker.
+// Pass the transaction's queries so the event is only delivered if the change it describes commits
+func publishEvent(ctx context.Context, q *database.Queries, eventType string, topics []string, payload any) error {
+	dat, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return q.InsertEvent(ctx, database.InsertEventParams{
+		Type:   eventType,
+		Topics: topics,
+		Data:   dat,
+	})
+}
+
+// Listens for event notifications and publishes the events to the local broker. Notifications only carry the id, the events
+// themselves are read from the table after the last one delivered, so after a reconnect or a dropped notification the listener
+// catches up with everything it missed. Events up to lastID were written before the instance started and aren't delivered
+func (cfg *apiConfig) listenForEvents(ctx context.Context, dbURL string, lastID int64) {
+	cursor := &eventCursor{lastID: lastID, gaps: map[int64]time.Time{}}
+
+	listener := pq.NewListener(dbURL, eventsListenerMinReconnect, eventsListenerMaxReconnect, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			fmt.Printf("Event listener connection error: %s\n", err)
+		}
+	})
+	defer listener.Close()
+	err := listener.Listen(eventsChannel)
+	if err != nil {
+		fmt.Printf("Error listening for events: %s\n", err)
+		return
+	}
+
+	ticker := time.NewTicker(eventsListenerPing)
+	defer ticker.Stop()
+	for {
+		//a nil notification means the connection was re-established and notifications may have been lost
+		select {
+		case <-ctx.Done():
+			return
+		case <-listener.Notify:
+		case <-ticker.C:
+			go listener.Ping()
+		}
+		cfg.catchUpEvents(ctx, cursor, time.Now())
+	}
+}
+
+// Publishes every stored event the cursor hasn't published yet, reading from the oldest open gap so events that
+// committed out of id order are still delivered. Events already published are skipped
+func (cfg *apiConfig) catchUpEvents(ctx context.Context, cursor *eventCursor, now time.Time) {
+	from := cursor.lastID
+	for id, noticed := range cursor.gaps {
+		if now.Sub(noticed) > eventsGapTimeout {
+			delete(cursor.gaps, id)
+		} else if id-1 < from {
+			from = id - 1
+		}
+	}
+
+	for {
+		rows, err := cfg.database.GetEventsAfter(ctx, database.GetEventsAfterParams{
+			ID:    from,
+			Limit: eventsCatchUpBatch,
+		})
+		if err != nil {
+			fmt.Printf("Error reading events after %d: %s\n", from, err)
+			return
+		}
+		for _, row := range rows {
+			from = row.ID
+			if !cursor.advance(row.ID, now) {
+				continue
+			}
+			cfg.events.Publish(broker.Event{
+				ID:     uint64(row.ID),
+				Type:   row.Type,
+				Topics: row.Topics,
+				Data:   row.Data,
+			})
+		}
+		if len(rows) < eventsCatchUpBatch {
+			return
+		}
+	}
+}
+
+// Records that id was read and reports whether it still needs publishing. Ids jumped over become gaps
+func (c *eventCursor) advance(id int64, now time.Time) bool {
+	if id <= c.lastID {
+		if _, waiting := c.gaps[id]; !waiting {
+			return false
+		}
+		delete(c.gaps, id)
+		return true
+	}
+	for missing := max(c.lastID+1, id-eventsMaxGap); missing < id; missing++ {
+		c.gaps[missing] = now
+	}
+	c.lastID = id
+	return true
+}
+
+// Deletes events older than the retention window, they only exist for listeners that fell behind
+func (cfg *apiConfig) pruneEventsJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pruned, err := cfg.database.PruneEvents(ctx, time.Now().UTC().Add(-eventsRetention))
+		if err != nil {
+			fmt.Printf("Failed to prune events: %s\n", err)
+		} else if pruned > 0 {
+			fmt.Printf("Pruned %d events\n", pruned)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
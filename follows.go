@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/pagination"
+	"net/http"
+	"time"
+)
+
+// how many of a user's recent chirps land in a new follower's timeline, older ones are only on their profile
+const timelineBackfillLimit = 200
+
+// entry in a followers or following list
+type FollowUser struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	FollowedAt  time.Time `json:"followed_at"`
+}
+
+// Follows the user with the handle in the path, following someone twice is a no-op
+func (cfg *apiConfig) followUser(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	//users blocked either way can't follow each other
+	blocked := false
+	followeeID, err := cfg.database.GetUserIDByHandle(r.Context(), r.PathValue("handle"))
+	if err == nil && followeeID != userID {
+		blocked, err = cfg.database.IsBlocked(r.Context(), database.IsBlockedParams{UserID: userID, OtherID: followeeID})
+	}
+	if err != nil || followeeID == userID || blocked {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for user"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "User not found"
+		} else if err == nil && blocked {
+			status = 403
+			rtn.Error = "You can't follow this user"
+		} else if err == nil {
+			status = 400
+			rtn.Error = "You can't follow yourself"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal follow lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	err = cfg.follow(r.Context(), userID, followeeID)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to follow user"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal follow error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error following user: %s\n", err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// Records the follow and copies the followee's recent chirps into the follower's timeline,
+// new chirps reach the timeline through the fan out trigger on chirps
+func (cfg *apiConfig) follow(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	added, err := qtx.FollowUser(ctx, database.FollowUserParams{FollowerID: followerID, FolloweeID: followeeID})
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return nil
+	}
+	err = qtx.BackfillTimeline(ctx, database.BackfillTimelineParams{
+		FollowerID:    followerID,
+		FolloweeID:    followeeID,
+		BackfillLimit: timelineBackfillLimit,
+	})
+	if err != nil {
+		return err
+	}
+	notifications, err := qtx.GetNewNotifications(ctx, followerID)
+	if err != nil {
+		return err
+	}
+	err = cfg.publishNotifications(ctx, qtx, notifications)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Unfollows the user with the handle in the path and removes their chirps from the caller's timeline
+func (cfg *apiConfig) unfollowUser(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	followeeID, err := cfg.database.GetUserIDByHandle(r.Context(), r.PathValue("handle"))
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for user"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "User not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unfollow lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	err = cfg.unfollow(r.Context(), userID, followeeID)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to unfollow user"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unfollow error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error unfollowing user: %s\n", err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) unfollow(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	removed, err := qtx.UnfollowUser(ctx, database.UnfollowUserParams{FollowerID: followerID, FolloweeID: followeeID})
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return nil
+	}
+	err = qtx.ClearTimelineAuthor(ctx, database.ClearTimelineAuthorParams{UserID: followerID, AuthorID: followeeID})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Lists who follows the user with the handle in the path, most recent first
+func (cfg *apiConfig) getFollowers(w http.ResponseWriter, r *http.Request) {
+	cfg.listFollows(w, r, true)
+}
+
+// Lists who the user with the handle in the path follows, most recent first
+func (cfg *apiConfig) getFollowing(w http.ResponseWriter, r *http.Request) {
+	cfg.listFollows(w, r, false)
+}
+
+// both lists share a row shape, followers picks which side of the follow the handle is on
+func (cfg *apiConfig) listFollows(w http.ResponseWriter, r *http.Request, followers bool) {
+	type response struct {
+		Users      []FollowUser `json:"users"`
+		NextCursor string       `json:"next_cursor,omitempty"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.database.GetUserIDByHandle(r.Context(), r.PathValue("handle"))
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for user"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "User not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal follow list lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	limit, err := pageLimit(r, 50, 200)
+	if err != nil {
+		rtn := &returnErrors{Error: "limit must be a positive number"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal follow list limit error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	listParams := database.GetFollowersParams{UserID: userID, PageLimit: limit}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			rtn := &returnErrors{Error: "Invalid cursor"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal follow list cursor error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+		listParams.CursorCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		listParams.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	var rows []database.GetFollowersRow
+	if followers {
+		rows, err = cfg.database.GetFollowers(r.Context(), listParams)
+	} else {
+		var followingRows []database.GetFollowingRow
+		followingRows, err = cfg.database.GetFollowing(r.Context(), database.GetFollowingParams(listParams))
+		for _, row := range followingRows {
+			rows = append(rows, database.GetFollowersRow(row))
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error querying follow list: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+
+	rtn := response{Users: make([]FollowUser, 0, len(rows))}
+	for _, row := range rows {
+		rtn.Users = append(rtn.Users, FollowUser{
+			ID:          row.ID,
+			Handle:      row.Handle.String,
+			DisplayName: row.DisplayName,
+			Bio:         row.Bio,
+			IsChirpyRed: row.IsChirpyRed,
+			FollowedAt:  row.FollowedAt,
+		})
+	}
+	if len(rows) == int(limit) {
+		last := rows[len(rows)-1]
+		rtn.NextCursor = pagination.EncodeCursor(last.FollowedAt, last.ID)
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling follow list: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Home timeline of the authenticated user, their own chirps and those of everyone they follow, newest first
+func (cfg *apiConfig) getTimeline(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Chirps     []Chirp `json:"chirps"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	limit, err := pageLimit(r, 20, 100)
+	if err != nil {
+		rtn := &returnErrors{Error: "limit must be a positive number"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal timeline limit error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	timelineParams := database.GetTimelineParams{UserID: userID, PageLimit: limit}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			rtn := &returnErrors{Error: "Invalid cursor"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal timeline cursor error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+		timelineParams.CursorCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		timelineParams.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	rows, err := cfg.database.GetTimeline(r.Context(), timelineParams)
+	if err != nil {
+		fmt.Printf("Error querying timeline: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	chirps, err := cfg.chirpResponses(r.Context(), rows, userID)
+	if err != nil {
+		fmt.Printf("Error building timeline chirps: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	rtn := response{Chirps: chirps}
+	//timeline entries copy the chirp's created_at so the last chirp is also the last entry
+	if len(rows) == int(limit) {
+		last := rows[len(rows)-1]
+		rtn.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling timeline: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
@@ -3,6 +3,7 @@ module github.com/statusquonjc46/chirpy-http
 go 1.24.3
 
 require (
+	github.com/coder/websocket v1.8.14
 	github.com/golang-jwt/jwt/v5 v5.2.2
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
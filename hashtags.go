@@ -0,0 +1,104 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/pagination"
+	"net/http"
+	"strings"
+)
+
+// Lists chirps tagged with a hashtag, newest first, paginated with a keyset cursor
+func (cfg *apiConfig) getHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Chirps     []Chirp `json:"chirps"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	//tags are stored lowercase without the #
+	tag := strings.ToLower(strings.TrimPrefix(r.PathValue("tag"), "#"))
+
+	limit, err := pageLimit(r, 20, 100)
+	if err != nil {
+		rtn := &returnErrors{Error: "limit must be a positive number"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal hashtag limit error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	viewerID, _ := cfg.userIDFromRequest(r)
+	hashtagParams := database.GetChirpsByHashtagParams{
+		Tag:       tag,
+		ViewerID:  uuid.NullUUID{UUID: viewerID, Valid: viewerID != uuid.Nil},
+		PageLimit: limit,
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			rtn := &returnErrors{Error: "Invalid cursor"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal hashtag cursor error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+		hashtagParams.CursorCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		hashtagParams.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	rows, err := cfg.database.GetChirpsByHashtag(r.Context(), hashtagParams)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to query DB for hashtag chirps"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal hashtag query error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error querying hashtag chirps: %s\n", err)
+		return
+	}
+
+	chirps, err := cfg.chirpResponses(r.Context(), rows, viewerID)
+	if err != nil {
+		fmt.Printf("Error building hashtag chirp responses: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	rtn := response{Chirps: chirps}
+	if len(rows) == int(limit) {
+		last := rows[len(rows)-1]
+		rtn.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling hashtag chirps: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
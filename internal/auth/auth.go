@@ -1,11 +1,18 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"strings"
 	"time"
 )
 
@@ -68,3 +75,89 @@ func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 		return uuid.Nil, errors.New("Unknown Error in JWT Validation")
 	}
 }
+
+// Returns when a valid JWT was issued, used to require a recent login for sensitive actions
+func GetJWTIssuedAt(tokenString, tokenSecret string) (time.Time, error) {
+	claimStruct := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claimStruct, func(token *jwt.Token) (interface{}, error) {
+		return []byte(tokenSecret), nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if claimStruct.IssuedAt == nil {
+		return time.Time{}, errors.New("token has no issued at claim")
+	}
+	return claimStruct.IssuedAt.Time, nil
+}
+
+// Pulls the token out of an "Authorization: Bearer <token>" header
+func GetBearerToken(headers http.Header) (string, error) {
+	authHeader := headers.Get("Authorization")
+	if authHeader == "" {
+		return "", errors.New("no authorization header included")
+	}
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	token = strings.TrimSpace(token)
+	if !found || token == "" {
+		return "", errors.New("malformed authorization header")
+	}
+	return token, nil
+}
+
+// Creates a random 256 bit refresh token, hex encoded
+func MakeRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Refresh tokens are stored as a sha256 hash so a leaked sessions table can't be replayed
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Pulls the key out of an "Authorization: ApiKey <key>" header
+func GetAPIKey(headers http.Header) (string, error) {
+	authHeader := headers.Get("Authorization")
+	if authHeader == "" {
+		return "", errors.New("no authorization header included")
+	}
+	key, found := strings.CutPrefix(authHeader, "ApiKey ")
+	key = strings.TrimSpace(key)
+	if !found || key == "" {
+		return "", errors.New("malformed authorization header")
+	}
+	return key, nil
+}
+
+// Compares two secrets without leaking timing information
+func SecretsMatch(got, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// Checks a "sha256=<hex>" HMAC-SHA256 signature over the raw request body
+func VerifyHMACSignature(body []byte, secret, signature string) error {
+	if secret == "" {
+		return errors.New("no signing secret configured")
+	}
+	sigHex, found := strings.CutPrefix(signature, "sha256=")
+	if !found {
+		return errors.New("malformed signature")
+	}
+	got, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return errors.New("malformed signature")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
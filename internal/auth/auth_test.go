@@ -1,6 +1,10 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
 	"testing"
 	"time"
 
@@ -109,3 +113,136 @@ func TestValidateJWT(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBearerToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		wantToken string
+		wantErr   bool
+	}{
+		{
+			name:      "Valid bearer token",
+			header:    "Bearer abc.def.ghi",
+			wantToken: "abc.def.ghi",
+			wantErr:   false,
+		},
+		{
+			name:      "Missing header",
+			header:    "",
+			wantToken: "",
+			wantErr:   true,
+		},
+		{
+			name:      "Wrong scheme",
+			header:    "Basic abc",
+			wantToken: "",
+			wantErr:   true,
+		},
+		{
+			name:      "Empty token",
+			header:    "Bearer ",
+			wantToken: "",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			if tt.header != "" {
+				headers.Set("Authorization", tt.header)
+			}
+			gotToken, err := GetBearerToken(headers)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetBearerToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotToken != tt.wantToken {
+				t.Errorf("GetBearerToken() gotToken = %v, want %v", gotToken, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestGetJWTIssuedAt(t *testing.T) {
+	before := time.Now().Add(-time.Second)
+	token, _ := MakeJWT(uuid.New(), "secret", time.Hour)
+
+	issuedAt, err := GetJWTIssuedAt(token, "secret")
+	if err != nil {
+		t.Fatalf("GetJWTIssuedAt() error = %v", err)
+	}
+	if issuedAt.Before(before) || issuedAt.After(time.Now()) {
+		t.Errorf("GetJWTIssuedAt() = %v, want around %v", issuedAt, before)
+	}
+
+	_, err = GetJWTIssuedAt(token, "wrong_secret")
+	if err == nil {
+		t.Errorf("GetJWTIssuedAt() with wrong secret should fail")
+	}
+}
+
+func TestVerifyHMACSignature(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded"}`)
+	validSig := "sha256=" + signForTest(body, "secret")
+
+	tests := []struct {
+		name      string
+		body      []byte
+		secret    string
+		signature string
+		wantErr   bool
+	}{
+		{
+			name:      "Valid signature",
+			body:      body,
+			secret:    "secret",
+			signature: validSig,
+			wantErr:   false,
+		},
+		{
+			name:      "Tampered body",
+			body:      []byte(`{"event":"user.downgraded"}`),
+			secret:    "secret",
+			signature: validSig,
+			wantErr:   true,
+		},
+		{
+			name:      "Wrong secret",
+			body:      body,
+			secret:    "wrong_secret",
+			signature: validSig,
+			wantErr:   true,
+		},
+		{
+			name:      "Missing prefix",
+			body:      body,
+			secret:    "secret",
+			signature: signForTest(body, "secret"),
+			wantErr:   true,
+		},
+		{
+			name:      "No secret configured",
+			body:      body,
+			secret:    "",
+			signature: validSig,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyHMACSignature(tt.body, tt.secret, tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyHMACSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func signForTest(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,176 @@
+package broker
+
+import (
+	"sync"
+)
+
+// Event is a published message, ID identifies it to clients resuming a stream
+type Event struct {
+	ID     uint64
+	Type   string
+	Topics []string
+	Data   []byte
+}
+
+// Broker fans events out to subscribers by topic and keeps the most recent events for replay.
+// Publishing never blocks, a subscriber that can't keep up is dropped and its channel closed
+type Broker struct {
+	mu         sync.Mutex
+	replay     []Event
+	evictedMax uint64
+	replaySize int
+	subBuffer  int
+	subs       map[*Subscription]struct{}
+	byTopic    map[string]map[*Subscription]struct{}
+}
+
+// Subscription receives the events matching any of its topics on C until it is closed
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	topics map[string]struct{}
+	broker *Broker
+}
+
+// New creates a broker that keeps the last replaySize events and gives each subscriber a channel with room for subBuffer events
+func New(replaySize, subBuffer int) *Broker {
+	return &Broker{
+		replaySize: replaySize,
+		subBuffer:  subBuffer,
+		subs:       make(map[*Subscription]struct{}),
+		byTopic:    make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Publish delivers the event to every matching subscriber and adds it to the replay buffer.
+// IDs come from the publisher and should mostly increase, subscribers are looked up per topic
+// so events addressed to many topics stay cheap
+func (b *Broker) Publish(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.replay = append(b.replay, ev)
+	if len(b.replay) > b.replaySize {
+		evicted := b.replay[:len(b.replay)-b.replaySize]
+		for _, old := range evicted {
+			b.evictedMax = max(b.evictedMax, old.ID)
+		}
+		b.replay = b.replay[len(evicted):]
+	}
+
+	delivered := make(map[*Subscription]struct{})
+	for _, topic := range ev.Topics {
+		for sub := range b.byTopic[topic] {
+			if _, ok := delivered[sub]; ok {
+				continue
+			}
+			delivered[sub] = struct{}{}
+			select {
+			case sub.c <- ev:
+			default:
+				b.remove(sub)
+			}
+		}
+	}
+}
+
+// Subscribe registers a subscription for topics. When lastEventID is non zero the buffered events after it that match
+// are returned too, registering and reading the buffer happen together so nothing published in between is missed.
+// complete is false when lastEventID is older than the buffer and some events could not be replayed
+func (b *Broker) Subscribe(topics []string, lastEventID uint64) (sub *Subscription, replay []Event, complete bool) {
+	c := make(chan Event, b.subBuffer)
+	sub = &Subscription{C: c, c: c, topics: make(map[string]struct{}), broker: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subs[sub] = struct{}{}
+	b.add(sub, topics)
+	complete = true
+	if lastEventID == 0 {
+		return sub, nil, complete
+	}
+	//ids can be sparse, the buffer only falls short if something newer than lastEventID was evicted
+	if b.evictedMax > lastEventID {
+		complete = false
+	}
+	for _, ev := range b.replay {
+		if ev.ID > lastEventID && sub.matches(ev) {
+			replay = append(replay, ev)
+		}
+	}
+	return sub, replay, complete
+}
+
+// Add subscribes to more topics, it does nothing once the subscription is closed
+func (s *Subscription) Add(topics ...string) {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	if _, ok := s.broker.subs[s]; !ok {
+		return
+	}
+	s.broker.add(s, topics)
+}
+
+// Remove stops delivery of the given topics
+func (s *Subscription) Remove(topics ...string) {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	for _, topic := range topics {
+		delete(s.topics, topic)
+		s.broker.unindex(s, topic)
+	}
+}
+
+// Subscribed reports whether the subscription currently receives topic
+func (s *Subscription) Subscribed(topic string) bool {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	_, ok := s.topics[topic]
+	return ok
+}
+
+// Close unregisters the subscription and closes C, it is safe to call more than once
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+// the helpers below are called with b.mu held
+func (b *Broker) add(sub *Subscription, topics []string) {
+	for _, topic := range topics {
+		sub.topics[topic] = struct{}{}
+		if b.byTopic[topic] == nil {
+			b.byTopic[topic] = make(map[*Subscription]struct{})
+		}
+		b.byTopic[topic][sub] = struct{}{}
+	}
+}
+
+func (b *Broker) unindex(sub *Subscription, topic string) {
+	delete(b.byTopic[topic], sub)
+	if len(b.byTopic[topic]) == 0 {
+		delete(b.byTopic, topic)
+	}
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	for topic := range sub.topics {
+		b.unindex(sub, topic)
+	}
+	delete(b.subs, sub)
+	close(sub.c)
+}
+
+func (s *Subscription) matches(ev Event) bool {
+	for _, topic := range ev.Topics {
+		if _, ok := s.topics[topic]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package broker
+
+import (
+	"testing"
+)
+
+func TestPublishMatchesTopics(t *testing.T) {
+	tests := []struct {
+		name      string
+		subTopics []string
+		pubTopics []string
+		want      bool
+	}{
+		{
+			name:      "Exact topic",
+			subTopics: []string{"chirps"},
+			pubTopics: []string{"chirps", "author:1"},
+			want:      true,
+		},
+		{
+			name:      "Any topic matches",
+			subTopics: []string{"hashtag:go", "author:2"},
+			pubTopics: []string{"chirps", "author:2"},
+			want:      true,
+		},
+		{
+			name:      "No overlap",
+			subTopics: []string{"hashtag:go"},
+			pubTopics: []string{"chirps", "hashtag:rust"},
+			want:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(10, 1)
+			sub, _, _ := b.Subscribe(tt.subTopics, 0)
+			defer sub.Close()
+
+			b.Publish(Event{ID: 1, Type: "chirp", Topics: tt.pubTopics, Data: []byte("x")})
+			select {
+			case <-sub.C:
+				if !tt.want {
+					t.Errorf("subscription to %v got event published to %v", tt.subTopics, tt.pubTopics)
+				}
+			default:
+				if tt.want {
+					t.Errorf("subscription to %v missed event published to %v", tt.subTopics, tt.pubTopics)
+				}
+			}
+		})
+	}
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	b := New(3, 10)
+	//ids are sparse like database sequences, the buffer ends up holding 5, 6 and 8
+	for i, topic := range []string{"a", "b", "a", "a", "a"} {
+		b.Publish(Event{ID: []uint64{1, 3, 5, 6, 8}[i], Type: "chirp", Topics: []string{topic}})
+	}
+
+	tests := []struct {
+		name         string
+		lastEventID  uint64
+		wantIDs      []uint64
+		wantComplete bool
+	}{
+		{
+			name:         "No last event ID",
+			lastEventID:  0,
+			wantIDs:      nil,
+			wantComplete: true,
+		},
+		{
+			name:         "Within the buffer",
+			lastEventID:  5,
+			wantIDs:      []uint64{6, 8},
+			wantComplete: true,
+		},
+		{
+			name:         "Last evicted event",
+			lastEventID:  3,
+			wantIDs:      []uint64{5, 6, 8},
+			wantComplete: true,
+		},
+		{
+			name:         "Older than the buffer",
+			lastEventID:  2,
+			wantIDs:      []uint64{5, 6, 8},
+			wantComplete: false,
+		},
+		{
+			name:         "Up to date",
+			lastEventID:  8,
+			wantIDs:      nil,
+			wantComplete: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay, complete := b.Subscribe([]string{"a"}, tt.lastEventID)
+			defer sub.Close()
+
+			var gotIDs []uint64
+			for _, ev := range replay {
+				gotIDs = append(gotIDs, ev.ID)
+			}
+			if len(gotIDs) != len(tt.wantIDs) {
+				t.Fatalf("Subscribe() replay = %v, want %v", gotIDs, tt.wantIDs)
+			}
+			for i := range gotIDs {
+				if gotIDs[i] != tt.wantIDs[i] {
+					t.Fatalf("Subscribe() replay = %v, want %v", gotIDs, tt.wantIDs)
+				}
+			}
+			if complete != tt.wantComplete {
+				t.Errorf("Subscribe() complete = %v, want %v", complete, tt.wantComplete)
+			}
+		})
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	b := New(10, 1)
+	slow, _, _ := b.Subscribe([]string{"a"}, 0)
+	fast, _, _ := b.Subscribe([]string{"a"}, 0)
+	defer fast.Close()
+
+	b.Publish(Event{ID: 1, Type: "chirp", Topics: []string{"a"}})
+	<-fast.C
+	b.Publish(Event{ID: 2, Type: "chirp", Topics: []string{"a"}})
+
+	//the slow subscriber still gets what fit in its buffer, then sees the channel closed
+	if _, ok := <-slow.C; !ok {
+		t.Fatal("slow subscriber lost its buffered event")
+	}
+	if _, ok := <-slow.C; ok {
+		t.Fatal("slow subscriber was not dropped")
+	}
+	if _, ok := <-fast.C; !ok {
+		t.Fatal("fast subscriber was dropped")
+	}
+	slow.Close()
+}
+
+func TestAddAndRemoveTopics(t *testing.T) {
+	b := New(10, 10)
+	sub, _, _ := b.Subscribe(nil, 0)
+	defer sub.Close()
+
+	tests := []struct {
+		name   string
+		add    []string
+		remove []string
+		topic  string
+		want   bool
+	}{
+		{
+			name:  "Nothing subscribed",
+			topic: "a",
+			want:  false,
+		},
+		{
+			name:  "Added topic",
+			add:   []string{"a", "b"},
+			topic: "a",
+			want:  true,
+		},
+		{
+			name:   "Removed topic",
+			remove: []string{"a"},
+			topic:  "a",
+			want:   false,
+		},
+		{
+			name:  "Other topic is kept",
+			topic: "b",
+			want:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub.Add(tt.add...)
+			sub.Remove(tt.remove...)
+			if got := sub.Subscribed(tt.topic); got != tt.want {
+				t.Errorf("Subscribed(%q) = %v, want %v", tt.topic, got, tt.want)
+			}
+			b.Publish(Event{ID: 1, Type: "chirp", Topics: []string{tt.topic, tt.topic}})
+			received := 0
+			for len(sub.C) > 0 {
+				<-sub.C
+				received++
+			}
+			if tt.want && received != 1 || !tt.want && received != 0 {
+				t.Errorf("received %d events for %q, want subscribed = %v", received, tt.topic, tt.want)
+			}
+		})
+	}
+}
@@ -12,20 +12,28 @@ import (
 )
 
 const addChirp = `-- name: AddChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (
-        gen_random_uuid(), NOW(), NOW(), $1, $2
-)
-RETURNING id, created_at, updated_at, body, user_id
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, quote_of_id, is_quote)
+SELECT new_chirp.id, NOW(), NOW(), $1, $2, $3, COALESCE($4::uuid, new_chirp.id), $5::uuid, $5::uuid IS NOT NULL
+FROM (SELECT gen_random_uuid() AS id) AS new_chirp
+RETURNING id, created_at, updated_at, body, user_id, search_vector, in_reply_to_id, conversation_id, like_count, rechirp_of_id, quote_of_id, is_quote
 `
 
 type AddChirpParams struct {
-	Body   string
-	UserID uuid.NullUUID
+	Body           string
+	UserID         uuid.NullUUID
+	InReplyToID    uuid.NullUUID
+	ConversationID uuid.NullUUID
+	QuoteOfID      uuid.NullUUID
 }
 
 func (q *Queries) AddChirp(ctx context.Context, arg AddChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, addChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, addChirp,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyToID,
+		arg.ConversationID,
+		arg.QuoteOfID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -33,6 +41,13 @@ func (q *Queries) AddChirp(ctx context.Context, arg AddChirpParams) (Chirp, erro
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.SearchVector,
+		&i.InReplyToID,
+		&i.ConversationID,
+		&i.LikeCount,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.IsQuote,
 	)
 	return i, err
 }
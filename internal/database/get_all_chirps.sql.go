@@ -7,14 +7,24 @@ package database
 
 import (
 	"context"
+
+	"github.com/google/uuid"
 )
 
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at ASC
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.in_reply_to_id, chirps.conversation_id, chirps.like_count, chirps.rechirp_of_id, chirps.quote_of_id, chirps.is_quote FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deleted_at IS NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM hidden_users
+		WHERE hidden_users.viewer_id = $1::uuid AND hidden_users.user_id = chirps.user_id
+	)
+ORDER BY chirps.created_at ASC
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+// users the viewer blocked, muted or was blocked by are left out, anonymous viewers see everyone
+func (q *Queries) GetAllChirps(ctx context.Context, viewerID uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -28,6 +38,13 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.SearchVector,
+			&i.InReplyToID,
+			&i.ConversationID,
+			&i.LikeCount,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.IsQuote,
 		); err != nil {
 			return nil, err
 		}
@@ -12,7 +12,9 @@ import (
 )
 
 const getSpecificChirp = `-- name: GetSpecificChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id=$1
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.in_reply_to_id, chirps.conversation_id, chirps.like_count, chirps.rechirp_of_id, chirps.quote_of_id, chirps.is_quote FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id=$1 AND users.deleted_at IS NULL
 `
 
 func (q *Queries) GetSpecificChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -24,6 +26,13 @@ func (q *Queries) GetSpecificChirp(ctx context.Context, id uuid.UUID) (Chirp, er
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.SearchVector,
+		&i.InReplyToID,
+		&i.ConversationID,
+		&i.LikeCount,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.IsQuote,
 	)
 	return i, err
 }
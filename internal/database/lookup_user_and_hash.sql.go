@@ -7,15 +7,14 @@ package database
 
 import (
 	"context"
-	"database/sql"
 )
 
 const userandHashLookup = `-- name: UserandHashLookup :one
-SELECT id, created_at, updated_at, email, hashed_password FROM users WHERE email=$1
+SELECT id, created_at, updated_at, email, hashed_password, deleted_at, purge_after, is_chirpy_red, handle, display_name, bio FROM users WHERE lower(email) = lower($1)
 `
 
-func (q *Queries) UserandHashLookup(ctx context.Context, email sql.NullString) (User, error) {
-	row := q.db.QueryRowContext(ctx, userandHashLookup, email)
+func (q *Queries) UserandHashLookup(ctx context.Context, lower string) (User, error) {
+	row := q.db.QueryRowContext(ctx, userandHashLookup, lower)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -23,6 +22,12 @@ func (q *Queries) UserandHashLookup(ctx context.Context, email sql.NullString) (
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
+		&i.DeletedAt,
+		&i.PurgeAfter,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
 	)
 	return i, err
 }
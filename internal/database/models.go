@@ -6,17 +6,278 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Body           string
+	UserID         uuid.NullUUID
+	SearchVector   interface{}
+	InReplyToID    uuid.NullUUID
+	ConversationID uuid.UUID
+	LikeCount      int32
+	RechirpOfID    uuid.NullUUID
+	QuoteOfID      uuid.NullUUID
+	IsQuote        bool
+}
+
+type ChirpHashtag struct {
+	ChirpID     uuid.UUID
+	HashtagID   uuid.UUID
+	StartOffset int32
+	EndOffset   int32
+}
+
+type ChirpLink struct {
+	ChirpID     uuid.UUID
+	Url         string
+	StartOffset int32
+	EndOffset   int32
+}
+
+type ChirpMedium struct {
+	ChirpID  uuid.UUID
+	MediaID  uuid.UUID
+	Position int32
+}
+
+type ChirpMention struct {
+	ChirpID     uuid.UUID
+	Handle      string
+	StartOffset int32
+	EndOffset   int32
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Body       string
+	CreatedAt  time.Time
+	ReplacedAt time.Time
+}
+
+type Conversation struct {
+	ID            uuid.UUID
+	DirectKey     sql.NullString
+	CreatedAt     time.Time
+	LastMessageAt time.Time
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type Draft struct {
 	ID        uuid.UUID
+	UserID    uuid.UUID
+	Body      string
+	MediaIds  []uuid.UUID
+	PublishAt sql.NullTime
+	LastError sql.NullString
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Body      string
-	UserID    uuid.NullUUID
+}
+
+type Event struct {
+	ID        int64
+	Type      string
+	Topics    []string
+	Data      json.RawMessage
+	CreatedAt time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Hashtag struct {
+	ID        uuid.UUID
+	Tag       string
+	CreatedAt time.Time
+}
+
+type HiddenUser struct {
+	ViewerID uuid.UUID
+	UserID   uuid.UUID
+	Blocked  bool
+}
+
+type Like struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type LinkPreview struct {
+	Url         string
+	Status      string
+	Title       string
+	Description string
+	ImageUrl    string
+	SiteName    string
+	Attempts    int32
+	LastError   sql.NullString
+	NextFetchAt sql.NullTime
+	FetchedAt   sql.NullTime
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type List struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ListMember struct {
+	ListID    uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type Medium struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	ContentType  string
+	Width        int32
+	Height       int32
+	SizeBytes    int32
+	StorageKey   string
+	ThumbnailKey string
+	CreatedAt    time.Time
+}
+
+type Message struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+	CreatedAt      time.Time
+}
+
+type ModerationAction struct {
+	ID          uuid.UUID
+	ModeratorID uuid.NullUUID
+	Action      string
+	ReportID    uuid.NullUUID
+	UserID      uuid.NullUUID
+	ChirpID     uuid.NullUUID
+	Note        string
+	CreatedAt   time.Time
+}
+
+type Moderator struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	ActorID   uuid.NullUUID
+	Type      string
+	ChirpID   uuid.NullUUID
+	CreatedAt time.Time
+	ReadAt    sql.NullTime
+	ReportID  uuid.NullUUID
+}
+
+type NotificationMute struct {
+	UserID uuid.UUID
+	Type   string
+}
+
+type OidcLoginState struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+	Restore      bool
+}
+
+type PaymentEvent struct {
+	ID         string
+	EventType  string
+	ReceivedAt time.Time
+}
+
+type Poll struct {
+	ChirpID   uuid.UUID
+	ClosesAt  time.Time
+	CreatedAt time.Time
+}
+
+type PollOption struct {
+	ID        uuid.UUID
+	ChirpID   uuid.UUID
+	Position  int32
+	Label     string
+	VoteCount int32
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	OptionID  uuid.UUID
+	CreatedAt time.Time
+}
+
+type RefreshToken struct {
+	ID         uuid.UUID
+	TokenHash  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	UserAgent  string
+	Ip         string
+	LastUsedAt time.Time
+}
+
+type Report struct {
+	ID         uuid.UUID
+	ReporterID uuid.UUID
+	UserID     uuid.UUID
+	ChirpID    uuid.NullUUID
+	ChirpBody  sql.NullString
+	Reason     string
+	Details    string
+	Status     string
+	CreatedAt  time.Time
+	ResolvedAt sql.NullTime
+}
+
+type TimelineEntry struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	AuthorID  uuid.UUID
+	CreatedAt time.Time
 }
 
 type User struct {
@@ -25,4 +286,71 @@ type User struct {
 	UpdatedAt      time.Time
 	Email          sql.NullString
 	HashedPassword string
+	DeletedAt      sql.NullTime
+	PurgeAfter     sql.NullTime
+	IsChirpyRed    bool
+	Handle         sql.NullString
+	DisplayName    string
+	Bio            string
+}
+
+type UserIdentity struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Issuer    string
+	Subject   string
+}
+
+type UserMute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type UserSuspension struct {
+	UserID         uuid.UUID
+	SuspendedAt    time.Time
+	SuspendedUntil sql.NullTime
+}
+
+type WebhookDeadLetter struct {
+	ID             uuid.UUID
+	EndpointID     uuid.UUID
+	UserID         uuid.NullUUID
+	Url            string
+	EventID        uuid.UUID
+	EventType      string
+	Attempts       int32
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	EndpointID     uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  sql.NullTime
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookEndpoint struct {
+	ID         uuid.UUID
+	UserID     uuid.NullUUID
+	Url        string
+	Secret     string
+	EventTypes []string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
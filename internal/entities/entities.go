@@ -0,0 +1,145 @@
+package entities
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Offsets are in characters (runes) of the chirp body, End is exclusive
+type Hashtag struct {
+	Tag   string `json:"tag"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type Mention struct {
+	Handle string `json:"handle"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+}
+
+type URL struct {
+	URL   string `json:"url"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type Entities struct {
+	Hashtags []Hashtag `json:"hashtags"`
+	Mentions []Mention `json:"mentions"`
+	URLs     []URL     `json:"urls"`
+}
+
+const maxTagLength = 50
+const maxHandleLength = 15
+const maxURLLength = 2048
+
+// Extract finds #hashtags, @mentions and http(s) URLs in a chirp body.
+// A marker only counts at the start of the body or after a character that can't be part of a word, so emails and c# don't match.
+// Tags are lowercased, handles keep their case. Markers inside a URL belong to the URL.
+func Extract(body string) Entities {
+	ents := Entities{Hashtags: []Hashtag{}, Mentions: []Mention{}, URLs: []URL{}}
+	runes := []rune(body)
+
+	for i := 0; i < len(runes); i++ {
+		if end := urlEnd(runes, i); end > i {
+			if end-i <= maxURLLength {
+				ents.URLs = append(ents.URLs, URL{URL: string(runes[i:end]), Start: i, End: end})
+			}
+			i = end - 1
+			continue
+		}
+
+		marker := runes[i]
+		if marker != '#' && marker != '@' {
+			continue
+		}
+		if i > 0 && (isWordRune(runes[i-1]) || runes[i-1] == '#' || runes[i-1] == '@') {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		word := string(runes[i+1 : end])
+		if word == "" {
+			continue
+		}
+
+		if marker == '#' {
+			if end-i-1 > maxTagLength || !hasLetter(word) {
+				continue
+			}
+			ents.Hashtags = append(ents.Hashtags, Hashtag{Tag: strings.ToLower(word), Start: i, End: end})
+		} else {
+			if end-i-1 > maxHandleLength || !isASCII(word) {
+				continue
+			}
+			ents.Mentions = append(ents.Mentions, Mention{Handle: word, Start: i, End: end})
+		}
+		i = end - 1
+	}
+	return ents
+}
+
+// urlEnd returns where a URL starting at i ends, or i when there isn't one. A URL runs to the next whitespace,
+// minus trailing punctuation and any closing bracket that wasn't opened inside it, so "(see https://x.com/a)." works
+func urlEnd(runes []rune, i int) int {
+	if i > 0 && isWordRune(runes[i-1]) {
+		return i
+	}
+	rest := string(runes[i:min(i+8, len(runes))])
+	prefixLen := 0
+	if strings.HasPrefix(strings.ToLower(rest), "https://") {
+		prefixLen = 8
+	} else if strings.HasPrefix(strings.ToLower(rest), "http://") {
+		prefixLen = 7
+	}
+	if prefixLen == 0 {
+		return i
+	}
+
+	end := i + prefixLen
+	for end < len(runes) && !unicode.IsSpace(runes[end]) {
+		end++
+	}
+	for end > i+prefixLen {
+		last := runes[end-1]
+		if strings.ContainsRune(".,!?;:'\"", last) {
+			end--
+		} else if last == ')' && strings.Count(string(runes[i:end]), "(") < strings.Count(string(runes[i:end]), ")") {
+			end--
+		} else {
+			break
+		}
+	}
+	//a scheme with nothing after it isn't a link
+	if end == i+prefixLen {
+		return i
+	}
+	return end
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// #2024 is a number, not a tag
+func hasLetter(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
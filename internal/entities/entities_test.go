@@ -0,0 +1,98 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		hashtags []Hashtag
+		mentions []Mention
+		urls     []URL
+	}{
+		{
+			name:     "Hashtag and mention",
+			body:     "hi @Lane check #GoLang",
+			hashtags: []Hashtag{{Tag: "golang", Start: 15, End: 22}},
+			mentions: []Mention{{Handle: "Lane", Start: 3, End: 8}},
+			urls:     []URL{},
+		},
+		{
+			name:     "Offsets count characters not bytes",
+			body:     "café #crème",
+			hashtags: []Hashtag{{Tag: "crème", Start: 5, End: 11}},
+			mentions: []Mention{},
+			urls:     []URL{},
+		},
+		{
+			name:     "Email and c# are not entities",
+			body:     "mail me at boots@example.com about c#",
+			hashtags: []Hashtag{},
+			mentions: []Mention{},
+			urls:     []URL{},
+		},
+		{
+			name:     "Numbers are not hashtags",
+			body:     "we're #1 in #2024goals",
+			hashtags: []Hashtag{{Tag: "2024goals", Start: 12, End: 22}},
+			mentions: []Mention{},
+			urls:     []URL{},
+		},
+		{
+			name:     "Punctuation ends an entity",
+			body:     "(@a_b), #x!",
+			hashtags: []Hashtag{{Tag: "x", Start: 8, End: 10}},
+			mentions: []Mention{{Handle: "a_b", Start: 1, End: 5}},
+			urls:     []URL{},
+		},
+		{
+			name:     "Bare markers",
+			body:     "# @ ## @@x",
+			hashtags: []Hashtag{},
+			mentions: []Mention{},
+			urls:     []URL{},
+		},
+		{
+			name:     "URL with fragment and at sign",
+			body:     "read https://example.com/@lane/post#top now",
+			hashtags: []Hashtag{},
+			mentions: []Mention{},
+			urls:     []URL{{URL: "https://example.com/@lane/post#top", Start: 5, End: 39}},
+		},
+		{
+			name:     "Trailing punctuation and brackets",
+			body:     "(see http://go.dev/doc), and HTTPS://en.wikipedia.org/wiki/Go_(language).",
+			hashtags: []Hashtag{},
+			mentions: []Mention{},
+			urls: []URL{
+				{URL: "http://go.dev/doc", Start: 5, End: 22},
+				{URL: "HTTPS://en.wikipedia.org/wiki/Go_(language)", Start: 29, End: 72},
+			},
+		},
+		{
+			name:     "Scheme alone or inside a word",
+			body:     "https:// and xhttps://example.com #go",
+			hashtags: []Hashtag{{Tag: "go", Start: 34, End: 37}},
+			mentions: []Mention{},
+			urls:     []URL{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Extract(tt.body)
+			if !reflect.DeepEqual(got.Hashtags, tt.hashtags) {
+				t.Errorf("Extract() hashtags = %+v, want %+v", got.Hashtags, tt.hashtags)
+			}
+			if !reflect.DeepEqual(got.Mentions, tt.mentions) {
+				t.Errorf("Extract() mentions = %+v, want %+v", got.Mentions, tt.mentions)
+			}
+			if !reflect.DeepEqual(got.URLs, tt.urls) {
+				t.Errorf("Extract() urls = %+v, want %+v", got.URLs, tt.urls)
+			}
+		})
+	}
+}
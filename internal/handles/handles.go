@@ -0,0 +1,48 @@
+package handles
+
+import (
+	"errors"
+	"strings"
+)
+
+const minLength = 3
+const maxLength = 15
+
+// handles that would collide with routes, staff or the product name
+var reserved = map[string]bool{
+	"about":     true,
+	"admin":     true,
+	"api":       true,
+	"app":       true,
+	"chirpy":    true,
+	"chirpyred": true,
+	"help":      true,
+	"login":     true,
+	"logout":    true,
+	"me":        true,
+	"mod":       true,
+	"moderator": true,
+	"null":      true,
+	"restore":   true,
+	"root":      true,
+	"settings":  true,
+	"signup":    true,
+	"support":   true,
+	"system":    true,
+}
+
+// Validate checks a handle is 3-15 ASCII letters, digits or underscores and not reserved (case insensitive)
+func Validate(handle string) error {
+	if len(handle) < minLength || len(handle) > maxLength {
+		return errors.New("handle must be between 3 and 15 characters")
+	}
+	for _, r := range handle {
+		if !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && r != '_' {
+			return errors.New("handle may only contain letters, numbers and underscores")
+		}
+	}
+	if reserved[strings.ToLower(handle)] {
+		return errors.New("handle is reserved")
+	}
+	return nil
+}
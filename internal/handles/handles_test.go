@@ -0,0 +1,51 @@
+package handles
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		handle  string
+		wantErr bool
+	}{
+		{
+			name:    "Valid handle",
+			handle:  "boots_the_bear",
+			wantErr: false,
+		},
+		{
+			name:    "Too short",
+			handle:  "ab",
+			wantErr: true,
+		},
+		{
+			name:    "Too long",
+			handle:  "abcdefghijklmnop",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid characters",
+			handle:  "boots.bear",
+			wantErr: true,
+		},
+		{
+			name:    "Non ASCII letters",
+			handle:  "crème",
+			wantErr: true,
+		},
+		{
+			name:    "Reserved any case",
+			handle:  "AdMin",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.handle)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
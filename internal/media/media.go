@@ -0,0 +1,121 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// MaxUploadSize is the largest file accepted, larger uploads are rejected before decoding
+const MaxUploadSize = 5 << 20
+
+// images are decoded in full to strip metadata, this caps the memory a small but huge-dimensioned file can cost
+const maxPixels = 40_000_000
+
+// ThumbnailSize is the longest side of a generated thumbnail
+const ThumbnailSize = 320
+
+const jpegQuality = 90
+
+var ErrUnsupportedType = errors.New("only JPEG and PNG images are supported")
+var ErrTooLarge = errors.New("image dimensions are too large")
+
+// Image is an upload after processing, Data and Thumbnail are re-encoded so EXIF and other metadata are gone
+type Image struct {
+	ContentType string
+	Width       int
+	Height      int
+	Data        []byte
+	Thumbnail   []byte
+}
+
+// Process sniffs the content type from the bytes themselves, ignoring whatever the client claimed, then decodes and
+// re-encodes the image and builds its thumbnail. Re-encoding drops the EXIF orientation too, images are stored as decoded
+func Process(data []byte) (Image, error) {
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return Image{}, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, err
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return Image{}, ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, err
+	}
+
+	out, err := encode(img, contentType)
+	if err != nil {
+		return Image{}, err
+	}
+	thumb, err := encode(Thumbnail(img, ThumbnailSize), contentType)
+	if err != nil {
+		return Image{}, err
+	}
+	return Image{
+		ContentType: contentType,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Data:        out,
+		Thumbnail:   thumb,
+	}, nil
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	return buf.Bytes(), err
+}
+
+// Thumbnail scales img down so its longest side is at most size, averaging the source pixels behind each output pixel.
+// Images that already fit are returned unchanged
+func Thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	tw, th = max(tw, 1), max(th, 1)
+
+	src := image.NewNRGBA(b)
+	draw.Draw(src, b, img, b.Min, draw.Src)
+	dst := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := y*h/th, max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := x*w/tw, max((x+1)*w/tw, x*w/tw+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := src.NRGBAAt(b.Min.X+sx, b.Min.Y+sy)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					bl += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,162 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// inserts an APP1 Exif segment right after the SOI marker, the way cameras write them
+func withExif(data []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), []byte("GPS 51.5074 N 0.1278 W")...)
+	size := len(payload) + 2
+	segment := append([]byte{0xFF, 0xE1, byte(size >> 8), byte(size)}, payload...)
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestProcess(t *testing.T) {
+	var pngBuf bytes.Buffer
+	err := png.Encode(&pngBuf, image.NewRGBA(image.Rect(0, 0, 100, 50)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		data            []byte
+		wantType        string
+		wantWidth       int
+		wantHeight      int
+		wantThumbWidth  int
+		wantThumbHeight int
+		wantErr         bool
+	}{
+		{
+			name:            "JPEG with EXIF",
+			data:            withExif(testJPEG(t, 640, 480)),
+			wantType:        "image/jpeg",
+			wantWidth:       640,
+			wantHeight:      480,
+			wantThumbWidth:  320,
+			wantThumbHeight: 240,
+		},
+		{
+			name:            "Small PNG keeps its size",
+			data:            pngBuf.Bytes(),
+			wantType:        "image/png",
+			wantWidth:       100,
+			wantHeight:      50,
+			wantThumbWidth:  100,
+			wantThumbHeight: 50,
+		},
+		{
+			name:    "Not an image",
+			data:    []byte("<html><body>hi</body></html>"),
+			wantErr: true,
+		},
+		{
+			name:    "GIF is not supported",
+			data:    []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;"),
+			wantErr: true,
+		},
+		{
+			name:    "Truncated JPEG",
+			data:    testJPEG(t, 64, 64)[:40],
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Process(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Process() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.ContentType != tt.wantType || got.Width != tt.wantWidth || got.Height != tt.wantHeight {
+				t.Errorf("Process() = %s %dx%d, want %s %dx%d", got.ContentType, got.Width, got.Height, tt.wantType, tt.wantWidth, tt.wantHeight)
+			}
+			if bytes.Contains(got.Data, []byte("Exif")) || bytes.Contains(got.Data, []byte("GPS")) {
+				t.Errorf("Process() kept EXIF metadata")
+			}
+			thumb, _, err := image.DecodeConfig(bytes.NewReader(got.Thumbnail))
+			if err != nil {
+				t.Fatalf("thumbnail doesn't decode: %v", err)
+			}
+			if thumb.Width != tt.wantThumbWidth || thumb.Height != tt.wantThumbHeight {
+				t.Errorf("thumbnail is %dx%d, want %dx%d", thumb.Width, thumb.Height, tt.wantThumbWidth, tt.wantThumbHeight)
+			}
+		})
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		name       string
+		width      int
+		height     int
+		wantWidth  int
+		wantHeight int
+	}{
+		{
+			name:       "Landscape",
+			width:      1000,
+			height:     500,
+			wantWidth:  320,
+			wantHeight: 160,
+		},
+		{
+			name:       "Portrait",
+			width:      500,
+			height:     1000,
+			wantWidth:  160,
+			wantHeight: 320,
+		},
+		{
+			name:       "Very thin",
+			width:      5000,
+			height:     2,
+			wantWidth:  320,
+			wantHeight: 1,
+		},
+		{
+			name:       "Already small",
+			width:      200,
+			height:     100,
+			wantWidth:  200,
+			wantHeight: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Thumbnail(image.NewRGBA(image.Rect(0, 0, tt.width, tt.height)), 320)
+			if got.Bounds().Dx() != tt.wantWidth || got.Bounds().Dy() != tt.wantHeight {
+				t.Errorf("Thumbnail() = %dx%d, want %dx%d", got.Bounds().Dx(), got.Bounds().Dy(), tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package media
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// s3StandIn is a minimal in-memory S3: it checks each request's signature, then stores, returns or deletes objects
+type s3StandIn struct {
+	secretKey string
+	mu        sync.Mutex
+	objects   map[string][]byte
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.validSignature(r) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		s.objects[r.URL.Path] = body
+	case http.MethodGet:
+		obj, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(obj)
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// recomputes the Signature V4 from what arrived on the wire
+func (s *s3StandIn) validSignature(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	cred, rest, ok := strings.Cut(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 Credential="), ", SignedHeaders=")
+	if !ok {
+		return false
+	}
+	signedHeaders, gotSig, ok := strings.Cut(rest, ", Signature=")
+	if !ok {
+		return false
+	}
+	_, scope, _ := strings.Cut(cred, "/")
+	parts := strings.Split(scope, "/")
+	if len(parts) != 4 {
+		return false
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(strings.NewReader(string(body)))
+	if sha256Hex(body) != r.Header.Get("X-Amz-Content-Sha256") {
+		return false
+	}
+	headers := ""
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers += name + ":" + value + "\n"
+	}
+	canonical := strings.Join([]string{r.Method, r.URL.EscapedPath(), r.URL.Query().Encode(), headers, signedHeaders, sha256Hex(body)}, "\n")
+	toSign := "AWS4-HMAC-SHA256\n" + r.Header.Get("X-Amz-Date") + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+	key := []byte("AWS4" + s.secretKey)
+	for _, p := range parts {
+		key = hmacSHA256(key, p)
+	}
+	return hex.EncodeToString(hmacSHA256(key, toSign)) == gotSig
+}
+
+func TestStorage(t *testing.T) {
+	standIn := &s3StandIn{secretKey: "test-secret", objects: map[string][]byte{}}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	s3 := NewS3Storage(server.URL, "chirpy-media", "us-east-1", "test-access", "test-secret")
+	s3.now = func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) }
+	badKey := NewS3Storage(server.URL, "chirpy-media", "us-east-1", "test-access", "wrong-secret")
+
+	tests := []struct {
+		name    string
+		storage Storage
+		wantErr bool
+	}{
+		{
+			name:    "Local filesystem",
+			storage: NewLocalStorage(t.TempDir()),
+		},
+		{
+			name:    "S3 compatible",
+			storage: s3,
+		},
+		{
+			name:    "S3 with the wrong secret",
+			storage: badKey,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			key := "media/0b6f4c1e.jpg"
+			err := tt.storage.Put(ctx, key, "image/jpeg", []byte("jpeg bytes"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Put() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			rc, err := tt.storage.Get(ctx, key)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			got, _ := io.ReadAll(rc)
+			rc.Close()
+			if string(got) != "jpeg bytes" {
+				t.Errorf("Get() = %q, want %q", got, "jpeg bytes")
+			}
+
+			err = tt.storage.Delete(ctx, key)
+			if err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			_, err = tt.storage.Get(ctx, key)
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+			}
+			err = tt.storage.Delete(ctx, key)
+			if err != nil {
+				t.Errorf("second Delete() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLocalStorageRejectsEscapingKeys(t *testing.T) {
+	s := NewLocalStorage(t.TempDir())
+	for _, key := range []string{"", "/etc/passwd", "../outside.jpg", "media/../../outside.jpg"} {
+		err := s.Put(context.Background(), key, "image/jpeg", []byte("x"))
+		if err == nil {
+			t.Errorf("Put(%q) succeeded, want an error", key)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIdP is a minimal identity provider that serves discovery, JWKS and a token endpoint
+type mockIdP struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	code     string
+	verifier string
+	nonce    string
+	audience string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	m := &mockIdP{key: key, clientID: "chirpy", code: "auth-code"}
+	m.audience = m.clientID
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != m.code || CodeChallenge(r.Form.Get("code_verifier")) != CodeChallenge(m.verifier) {
+			w.WriteHeader(400)
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, &Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    m.server.URL,
+				Subject:   "external-user-1",
+				Audience:  jwt.ClaimStrings{m.audience},
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			Nonce:         m.nonce,
+			Email:         "user@example.com",
+			EmailVerified: true,
+		})
+		token.Header["kid"] = "test-key"
+		signed, err := token.SignedString(m.key)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	idp := newMockIdP(t)
+	p, err := Discover(context.Background(), idp.server.URL, idp.clientID, "", "http://localhost:8080/callback")
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	authURL, err := url.Parse(p.AuthCodeURL("state123", "nonce123", "verifier123"))
+	if err != nil {
+		t.Fatalf("AuthCodeURL() returned an invalid url: %v", err)
+	}
+	q := authURL.Query()
+	if q.Get("state") != "state123" || q.Get("nonce") != "nonce123" {
+		t.Errorf("AuthCodeURL() state/nonce = %q/%q", q.Get("state"), q.Get("nonce"))
+	}
+	if q.Get("code_challenge") != CodeChallenge("verifier123") || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("AuthCodeURL() has wrong PKCE parameters: %v", q)
+	}
+}
+
+func TestExchangeAndVerify(t *testing.T) {
+	tests := []struct {
+		name       string
+		verifier   string
+		nonce      string
+		audience   string
+		checkNonce string
+		wantErr    bool
+	}{
+		{
+			name:       "Valid flow",
+			verifier:   "verifier",
+			nonce:      "nonce",
+			audience:   "chirpy",
+			checkNonce: "nonce",
+			wantErr:    false,
+		},
+		{
+			name:       "Wrong PKCE verifier",
+			verifier:   "other-verifier",
+			nonce:      "nonce",
+			audience:   "chirpy",
+			checkNonce: "nonce",
+			wantErr:    true,
+		},
+		{
+			name:       "Nonce mismatch",
+			verifier:   "verifier",
+			nonce:      "nonce",
+			audience:   "chirpy",
+			checkNonce: "different-nonce",
+			wantErr:    true,
+		},
+		{
+			name:       "Wrong audience",
+			verifier:   "verifier",
+			nonce:      "nonce",
+			audience:   "someone-else",
+			checkNonce: "nonce",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockIdP(t)
+			idp.verifier = "verifier"
+			idp.nonce = tt.nonce
+			idp.audience = tt.audience
+
+			p, err := Discover(context.Background(), idp.server.URL, idp.clientID, "secret", "http://localhost:8080/callback")
+			if err != nil {
+				t.Fatalf("Discover() error = %v", err)
+			}
+
+			rawIDToken, err := p.Exchange(context.Background(), idp.code, tt.verifier)
+			if err == nil {
+				var claims *Claims
+				claims, err = p.VerifyIDToken(context.Background(), rawIDToken, tt.checkNonce)
+				if err == nil && claims.Subject != "external-user-1" {
+					t.Errorf("VerifyIDToken() subject = %q", claims.Subject)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Exchange()/VerifyIDToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
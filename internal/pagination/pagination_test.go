@@ -0,0 +1,28 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	id := uuid.New()
+	createdAt := time.Date(2025, 6, 1, 12, 30, 0, 123456000, time.UTC)
+
+	gotCreatedAt, gotID, err := DecodeCursor(EncodeCursor(createdAt, id))
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+	if !gotCreatedAt.Equal(createdAt) || gotID != id {
+		t.Errorf("DecodeCursor() = %v, %v, want %v, %v", gotCreatedAt, gotID, createdAt, id)
+	}
+
+	for _, bad := range []string{"", "%%%", "bm9waXBl", "MTIzfG5vdC1hLXV1aWQ"} {
+		_, _, err := DecodeCursor(bad)
+		if err == nil {
+			t.Errorf("DecodeCursor(%q) should fail", bad)
+		}
+	}
+}
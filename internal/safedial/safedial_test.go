@@ -0,0 +1,75 @@
+package safedial
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "93.184.216.34", want: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{addr: "127.0.0.1", want: false},
+		{addr: "10.1.2.3", want: false},
+		{addr: "172.16.0.1", want: false},
+		{addr: "192.168.1.1", want: false},
+		{addr: "169.254.169.254", want: false},
+		{addr: "100.64.0.1", want: false},
+		{addr: "0.0.0.0", want: false},
+		{addr: "::1", want: false},
+		{addr: "fd00::1", want: false},
+		{addr: "fe80::1", want: false},
+		{addr: "::ffff:127.0.0.1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := PublicIP(net.ParseIP(tt.addr)); got != tt.want {
+				t.Errorf("PublicIP(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		allowed     func(ip net.IP, port string) bool
+		wantBlocked bool
+	}{
+		{
+			name:    "Allowed address",
+			allowed: func(ip net.IP, port string) bool { return ip.IsLoopback() },
+		},
+		{
+			name:        "Blocked address",
+			allowed:     func(ip net.IP, port string) bool { return PublicIP(ip) },
+			wantBlocked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: Transport(time.Second, tt.allowed)}
+			req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, nil)
+			resp, err := client.Do(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if blocked := errors.Is(err, ErrBlockedAddress); blocked != tt.wantBlocked {
+				t.Errorf("Do() error = %v, want blocked %v", err, tt.wantBlocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package search
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseQuery turns a user search string into a postgres to_tsquery expression.
+// Words are ANDed, "quoted text" is a phrase, word* is a prefix match, -word excludes and OR between terms means either.
+// Everything that isn't a letter or digit is dropped so user input can't inject tsquery operators.
+func ParseQuery(q string) (string, error) {
+	var terms []string
+	orNext := false
+
+	for i := 0; i < len(q); {
+		r, size := utf8.DecodeRuneInString(q[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '"':
+			end := strings.IndexByte(q[i+1:], '"')
+			var phrase string
+			if end == -1 {
+				phrase = q[i+1:]
+				i = len(q)
+			} else {
+				phrase = q[i+1 : i+1+end]
+				i += end + 2
+			}
+			if term := phraseTerm(phrase, false); term != "" {
+				terms = appendTerm(terms, term, &orNext)
+			}
+		default:
+			end := strings.IndexFunc(q[i:], unicode.IsSpace)
+			if end == -1 {
+				end = len(q) - i
+			}
+			word := q[i : i+end]
+			i += end
+
+			if word == "OR" && len(terms) > 0 {
+				orNext = true
+				continue
+			}
+			negate := strings.HasPrefix(word, "-")
+			prefix := strings.HasSuffix(word, "*")
+			word = strings.TrimSuffix(strings.TrimPrefix(word, "-"), "*")
+
+			term := phraseTerm(word, prefix)
+			if term == "" {
+				continue
+			}
+			if negate {
+				term = "!" + term
+			}
+			terms = appendTerm(terms, term, &orNext)
+		}
+	}
+
+	if len(terms) == 0 {
+		return "", errors.New("search query has no searchable terms")
+	}
+	return strings.Join(terms, " "), nil
+}
+
+// appendTerm joins the new term to the previous one with & or | depending on whether OR came before it
+func appendTerm(terms []string, term string, orNext *bool) []string {
+	if len(terms) > 0 {
+		op := "&"
+		if *orNext {
+			op = "|"
+		}
+		terms = append(terms, op)
+	}
+	*orNext = false
+	return append(terms, term)
+}
+
+// phraseTerm splits text into lexemes and joins them with the followed-by operator, prefix applies to the last lexeme
+func phraseTerm(text string, prefix bool) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+	if prefix {
+		words[len(words)-1] += ":*"
+	}
+	if len(words) == 1 {
+		return words[0]
+	}
+	return "(" + strings.Join(words, " <-> ") + ")"
+}
+
+// EncodeCursor packs the rank and id of the last result into an opaque pagination cursor
+func EncodeCursor(rank float32, id uuid.UUID) string {
+	raw := fmt.Sprintf("%d|%s", math.Float32bits(rank), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor reverses EncodeCursor
+func DecodeCursor(cursor string) (float32, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, uuid.Nil, errors.New("invalid cursor")
+	}
+	bitsStr, idStr, found := strings.Cut(string(raw), "|")
+	if !found {
+		return 0, uuid.Nil, errors.New("invalid cursor")
+	}
+	bits, err := strconv.ParseUint(bitsStr, 10, 32)
+	if err != nil {
+		return 0, uuid.Nil, errors.New("invalid cursor")
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return 0, uuid.Nil, errors.New("invalid cursor")
+	}
+	return math.Float32frombits(uint32(bits)), id, nil
+}
@@ -0,0 +1,102 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Single word",
+			query:   "chirpy",
+			want:    "chirpy",
+			wantErr: false,
+		},
+		{
+			name:    "Words are ANDed",
+			query:   "Hello  World",
+			want:    "hello & world",
+			wantErr: false,
+		},
+		{
+			name:    "Phrase",
+			query:   `"good morning" coffee`,
+			want:    "(good <-> morning) & coffee",
+			wantErr: false,
+		},
+		{
+			name:    "Prefix",
+			query:   "chirp*",
+			want:    "chirp:*",
+			wantErr: false,
+		},
+		{
+			name:    "OR and negation",
+			query:   "cats OR dogs -birds",
+			want:    "cats | dogs & !birds",
+			wantErr: false,
+		},
+		{
+			name:    "Operators are stripped",
+			query:   "a&b | !c:*",
+			want:    "(a <-> b) & c:*",
+			wantErr: false,
+		},
+		{
+			name:    "Unclosed phrase",
+			query:   `"open phrase`,
+			want:    "(open <-> phrase)",
+			wantErr: false,
+		},
+		{
+			name:    "Multibyte whitespace",
+			query:   "foo\u3000bar\u00a0\u3000baz",
+			want:    "foo & bar & baz",
+			wantErr: false,
+		},
+		{
+			name:    "Nothing searchable",
+			query:   `  "" -* !!`,
+			want:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseQuery() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	id := uuid.New()
+	rank := float32(0.0607927)
+
+	gotRank, gotID, err := DecodeCursor(EncodeCursor(rank, id))
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+	if gotRank != rank || gotID != id {
+		t.Errorf("DecodeCursor() = %v, %v, want %v, %v", gotRank, gotID, rank, id)
+	}
+
+	_, _, err = DecodeCursor("not-a-cursor")
+	if err == nil {
+		t.Errorf("DecodeCursor() should fail on garbage input")
+	}
+}
@@ -0,0 +1,224 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/statusquonjc46/chirpy-http/internal/safedial"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const fetchTimeout = 5 * time.Second
+const maxRedirects = 3
+
+// only the head matters, pages are cut off here whether or not it has ended
+const maxBodySize = 512 << 10
+
+// longest title and description kept, in characters
+const maxTitleLength = 200
+const maxDescriptionLength = 500
+
+var ErrBlockedAddress = safedial.ErrBlockedAddress
+
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+// Fetcher retrieves link previews. Connections are only made to public addresses on ports 80 and 443, the check runs
+// on the resolved address at dial time so DNS answers and redirects can't point it at the internal network
+type Fetcher struct {
+	client *http.Client
+}
+
+func NewFetcher() *Fetcher {
+	return newFetcher(allowedAddress)
+}
+
+func newFetcher(allowed func(ip net.IP, port string) bool) *Fetcher {
+	return &Fetcher{client: &http.Client{
+		Transport: safedial.Transport(fetchTimeout, allowed),
+		Timeout:   fetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return errors.New("redirect to a non http URL")
+			}
+			return nil
+		},
+	}}
+}
+
+func allowedAddress(ip net.IP, port string) bool {
+	return (port == "80" || port == "443") && safedial.PublicIP(ip)
+}
+
+// Fetch downloads the page at rawURL and reads its OpenGraph and Twitter card metadata
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Preview{}, errors.New("not an http URL")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	req.Header.Set("User-Agent", "ChirpyBot/1.0 (link previews)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Preview{}, fmt.Errorf("page responded %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Preview{}, fmt.Errorf("page is %q, not HTML", mediaType)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return Preview{}, err
+	}
+
+	//relative image URLs resolve against where the page ended up after redirects
+	preview := Parse(string(body), resp.Request.URL)
+	preview.URL = rawURL
+	if preview.Title == "" && preview.Description == "" {
+		return Preview{}, errors.New("page has no preview metadata")
+	}
+	return preview, nil
+}
+
+// Parse reads the preview metadata from a page's head. OpenGraph tags win over Twitter card tags, which win over
+// <title> and the plain description meta tag
+func Parse(page string, base *url.URL) Preview {
+	lower := asciiLower(page)
+	if end := strings.Index(lower, "</head>"); end >= 0 {
+		page, lower = page[:end], lower[:end]
+	}
+
+	meta := map[string]string{}
+	for i := 0; ; {
+		start := strings.Index(lower[i:], "<meta")
+		if start < 0 {
+			break
+		}
+		start += i
+		end := strings.IndexByte(lower[start:], '>')
+		if end < 0 {
+			break
+		}
+		end += start
+		attrs := parseAttributes(page[start+len("<meta") : end])
+		key := strings.ToLower(attrs["property"])
+		if key == "" {
+			key = strings.ToLower(attrs["name"])
+		}
+		if _, seen := meta[key]; key != "" && !seen {
+			meta[key] = strings.TrimSpace(html.UnescapeString(attrs["content"]))
+		}
+		i = end
+	}
+
+	title := ""
+	if start := strings.Index(lower, "<title"); start >= 0 {
+		if open := strings.IndexByte(lower[start:], '>'); open >= 0 {
+			rest := start + open + 1
+			if end := strings.Index(lower[rest:], "</title"); end >= 0 {
+				title = strings.TrimSpace(html.UnescapeString(page[rest : rest+end]))
+			}
+		}
+	}
+
+	preview := Preview{
+		Title:       truncate(firstNonEmpty(meta["og:title"], meta["twitter:title"], title), maxTitleLength),
+		Description: truncate(firstNonEmpty(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescriptionLength),
+		SiteName:    truncate(meta["og:site_name"], maxTitleLength),
+	}
+	if img := firstNonEmpty(meta["og:image"], meta["og:image:url"], meta["twitter:image"]); img != "" {
+		if ref, err := url.Parse(img); err == nil {
+			abs := base.ResolveReference(ref)
+			if abs.Scheme == "http" || abs.Scheme == "https" {
+				preview.ImageURL = abs.String()
+			}
+		}
+	}
+	return preview
+}
+
+// Lowercases ASCII letters only. Every index found in the result is also an index into s, which strings.ToLower
+// doesn't promise since it rewrites invalid UTF-8 and some runes change length when lowercased
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + ('a' - 'A')
+		}
+	}
+	return string(b)
+}
+
+// reads name="value", name='value' and name=value pairs, names are lowercased
+func parseAttributes(s string) map[string]string {
+	attrs := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " \t\r\n/")
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return attrs
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:eq]))
+		if sp := strings.LastIndexAny(name, " \t\r\n"); sp >= 0 {
+			//valueless attributes like itemprop before this one
+			name = name[sp+1:]
+		}
+		s = strings.TrimLeft(s[eq+1:], " \t\r\n")
+		value := ""
+		if s != "" && (s[0] == '"' || s[0] == '\'') {
+			end := strings.IndexByte(s[1:], s[0])
+			if end < 0 {
+				return attrs
+			}
+			value, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.IndexAny(s, " \t\r\n")
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = s[:end], s[end:]
+		}
+		attrs[name] = value
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
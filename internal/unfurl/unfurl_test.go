@@ -0,0 +1,194 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://example.com/posts/1")
+
+	tests := []struct {
+		name string
+		page string
+		want Preview
+	}{
+		{
+			name: "OpenGraph",
+			page: `<html><head>
+				<title>Ignored</title>
+				<meta property="og:title" content="Boots &amp; Friends">
+				<meta property="og:description" content='A bear&#39;s blog'>
+				<meta property="og:image" content="/img/cover.png" />
+				<meta property="og:site_name" content="Example">
+				</head><body></body></html>`,
+			want: Preview{
+				Title:       "Boots & Friends",
+				Description: "A bear's blog",
+				ImageURL:    "https://example.com/img/cover.png",
+				SiteName:    "Example",
+			},
+		},
+		{
+			name: "Twitter card and attribute order",
+			page: `<head><meta content="Card title" name="twitter:title"><META NAME=twitter:description CONTENT=short>
+				<meta name="twitter:image" content="https://cdn.example.com/a.jpg"></head>`,
+			want: Preview{
+				Title:       "Card title",
+				Description: "short",
+				ImageURL:    "https://cdn.example.com/a.jpg",
+			},
+		},
+		{
+			name: "Fallback to title and description",
+			page: `<head><title> Plain page </title><meta name="description" content="Just a page"></head>`,
+			want: Preview{
+				Title:       "Plain page",
+				Description: "Just a page",
+			},
+		},
+		{
+			name: "Body tags and script images are ignored",
+			page: `<head><title>Head</title><meta property="og:image" content="javascript:alert(1)"></head>
+				<body><meta property="og:title" content="Body"></body>`,
+			want: Preview{
+				Title: "Head",
+			},
+		},
+		{
+			name: "Invalid UTF-8 and runes that change length when lowercased",
+			page: strings.Repeat("\xff", 100) + "<html><head>\u0130\u0130<title>x</title>" +
+				`<meta property="og:description" content="d"></head></html>`,
+			want: Preview{
+				Title:       "x",
+				Description: "d",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.page, base)
+			if got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<head><meta property="og:title" content="Hello"></head>`))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(strings.Repeat("x", maxBodySize) + `<head><title>Too late</title></head>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	//the test server is on loopback, so it has to be let through explicitly
+	loopbackOK := newFetcher(func(ip net.IP, port string) bool { return ip.IsLoopback() })
+
+	tests := []struct {
+		name        string
+		fetcher     *Fetcher
+		path        string
+		wantTitle   string
+		wantErr     bool
+		wantBlocked bool
+	}{
+		{
+			name:      "Page",
+			fetcher:   loopbackOK,
+			path:      "/page",
+			wantTitle: "Hello",
+		},
+		{
+			name:      "Redirect",
+			fetcher:   loopbackOK,
+			path:      "/redirect",
+			wantTitle: "Hello",
+		},
+		{
+			name:    "Not HTML",
+			fetcher: loopbackOK,
+			path:    "/image",
+			wantErr: true,
+		},
+		{
+			name:    "Metadata past the size cap",
+			fetcher: loopbackOK,
+			path:    "/huge",
+			wantErr: true,
+		},
+		{
+			name:        "Loopback blocked by default",
+			fetcher:     NewFetcher(),
+			path:        "/page",
+			wantErr:     true,
+			wantBlocked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.fetcher.Fetch(context.Background(), server.URL+tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantBlocked && !errors.Is(err, ErrBlockedAddress) {
+				t.Errorf("Fetch() error = %v, want ErrBlockedAddress", err)
+			}
+			if err == nil && got.Title != tt.wantTitle {
+				t.Errorf("Fetch() title = %q, want %q", got.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestAllowedAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		port string
+		want bool
+	}{
+		{addr: "93.184.216.34", port: "443", want: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", port: "80", want: true},
+		{addr: "93.184.216.34", port: "22", want: false},
+		{addr: "127.0.0.1", port: "80", want: false},
+		{addr: "10.1.2.3", port: "80", want: false},
+		{addr: "172.16.0.1", port: "80", want: false},
+		{addr: "192.168.1.1", port: "443", want: false},
+		{addr: "169.254.169.254", port: "80", want: false},
+		{addr: "100.64.0.1", port: "80", want: false},
+		{addr: "0.0.0.0", port: "80", want: false},
+		{addr: "::1", port: "443", want: false},
+		{addr: "fd00::1", port: "443", want: false},
+		{addr: "fe80::1", port: "443", want: false},
+		{addr: "::ffff:127.0.0.1", port: "80", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr+":"+tt.port, func(t *testing.T) {
+			if got := allowedAddress(net.ParseIP(tt.addr), tt.port); got != tt.want {
+				t.Errorf("allowedAddress(%s, %s) = %v, want %v", tt.addr, tt.port, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/statusquonjc46/chirpy-http/internal/safedial"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// event types endpoints can subscribe to
+const (
+	ChirpCreated = "chirp.created"
+	ChirpDeleted = "chirp.deleted"
+	UserCreated  = "user.created"
+)
+
+var EventTypes = []string{ChirpCreated, ChirpDeleted, UserCreated}
+
+const baseBackoff = 30 * time.Second
+const maxBackoff = 6 * time.Hour
+
+// Sign returns the "sha256=<hex>" HMAC-SHA256 of "<timestamp>.<body>". The timestamp is signed with the body so a
+// captured request can't be replayed later with a fresh timestamp header
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff is how long to wait before retrying after the given number of failed attempts, doubling from 30s up to 6h
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+// ValidateURL checks an endpoint URL is absolute http or https and doesn't name a host on the internal network.
+// Hostnames that resolve there are caught when delivering, this just turns the obvious cases away early
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if ip := net.ParseIP(host); (ip != nil && !safedial.PublicIP(ip)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("url must point at a public address")
+	}
+	return nil
+}
+
+// NewClient returns the client deliveries are sent with. It only connects to public addresses and doesn't follow
+// redirects, the redirect response itself counts as a failed delivery
+func NewClient(timeout time.Duration) *http.Client {
+	return newClient(timeout, func(ip net.IP, _ string) bool { return safedial.PublicIP(ip) })
+}
+
+func newClient(timeout time.Duration, allowed func(ip net.IP, port string) bool) *http.Client {
+	return &http.Client{
+		Transport: safedial.Transport(timeout, allowed),
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,229 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/statusquonjc46/chirpy-http/internal/safedial"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"chirp.created"}`)
+	ts := time.Unix(1700000000, 0)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp time.Time
+		body      []byte
+		wantMatch bool
+	}{
+		{
+			name:      "Same inputs",
+			secret:    "secret",
+			timestamp: ts,
+			body:      body,
+			wantMatch: true,
+		},
+		{
+			name:      "Different secret",
+			secret:    "other",
+			timestamp: ts,
+			body:      body,
+			wantMatch: false,
+		},
+		{
+			name:      "Different timestamp",
+			secret:    "secret",
+			timestamp: ts.Add(time.Second),
+			body:      body,
+			wantMatch: false,
+		},
+		{
+			name:      "Different body",
+			secret:    "secret",
+			timestamp: ts,
+			body:      []byte(`{"type":"chirp.deleted"}`),
+			wantMatch: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sign(tt.secret, tt.timestamp, tt.body)
+			if (got == want) != tt.wantMatch {
+				t.Errorf("Sign() = %q, want match %v with %q", got, tt.wantMatch, want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		want     time.Duration
+	}{
+		{
+			name:     "No failures",
+			attempts: 0,
+			want:     0,
+		},
+		{
+			name:     "First failure",
+			attempts: 1,
+			want:     30 * time.Second,
+		},
+		{
+			name:     "Doubles",
+			attempts: 3,
+			want:     2 * time.Minute,
+		},
+		{
+			name:     "Capped",
+			attempts: 20,
+			want:     6 * time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Backoff(tt.attempts); got != tt.want {
+				t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{
+			name:    "HTTPS URL",
+			url:     "https://example.com/hooks/chirpy",
+			wantErr: false,
+		},
+		{
+			name:    "HTTP URL with a port",
+			url:     "http://hooks.example.com:9000/hook",
+			wantErr: false,
+		},
+		{
+			name:    "Localhost",
+			url:     "http://localhost:9000/hook",
+			wantErr: true,
+		},
+		{
+			name:    "Private address",
+			url:     "http://10.0.0.5/hook",
+			wantErr: true,
+		},
+		{
+			name:    "Cloud metadata address",
+			url:     "http://169.254.169.254/latest/meta-data",
+			wantErr: true,
+		},
+		{
+			name:    "Loopback IPv6",
+			url:     "http://[::1]:8080/hook",
+			wantErr: true,
+		},
+		{
+			name:    "Relative URL",
+			url:     "/hooks/chirpy",
+			wantErr: true,
+		},
+		{
+			name:    "Other scheme",
+			url:     "ftp://example.com/hook",
+			wantErr: true,
+		},
+		{
+			name:    "Empty",
+			url:     "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClient(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/hook", http.StatusTemporaryRedirect)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	//the test server is on loopback, so it has to be let through explicitly
+	loopbackOK := newClient(time.Second, func(ip net.IP, port string) bool { return ip.IsLoopback() })
+
+	tests := []struct {
+		name        string
+		client      *http.Client
+		path        string
+		wantStatus  int
+		wantBlocked bool
+	}{
+		{
+			name:       "Delivered",
+			client:     loopbackOK,
+			path:       "/hook",
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "Redirects aren't followed",
+			client:     loopbackOK,
+			path:       "/redirect",
+			wantStatus: http.StatusTemporaryRedirect,
+		},
+		{
+			name:        "Loopback blocked by default",
+			client:      NewClient(time.Second),
+			path:        "/hook",
+			wantBlocked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.client.Post(server.URL+tt.path, "application/json", nil)
+			if tt.wantBlocked {
+				if !errors.Is(err, safedial.ErrBlockedAddress) {
+					t.Errorf("Post() error = %v, want ErrBlockedAddress", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Post() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Post() status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"net/http"
+)
+
+// Likes a chirp for the authenticated user, liking twice is a no-op
+func (cfg *apiConfig) likeChirp(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err == nil {
+		_, err = cfg.visibleChirp(r.Context(), chirpID, userID)
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for chirpID"}
+		if errors.Is(err, sql.ErrNoRows) || chirpID == uuid.Nil {
+			status = 404
+			rtn.Error = "Chirp not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal like chirp lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	err = cfg.like(r.Context(), userID, chirpID)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to like chirp"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal like error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error liking chirp: %s\n", err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// Records the like and publishes the notification it caused to the chirp's author
+func (cfg *apiConfig) like(ctx context.Context, userID, chirpID uuid.UUID) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	_, err = qtx.LikeChirp(ctx, database.LikeChirpParams{UserID: userID, ChirpID: chirpID})
+	if err != nil {
+		return err
+	}
+	notifications, err := qtx.GetNewNotifications(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = cfg.publishNotifications(ctx, qtx, notifications)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Removes the authenticated user's like, unliking a chirp that isn't liked is a no-op
+func (cfg *apiConfig) unlikeChirp(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		rtn := &returnErrors{Error: "Invalid chirp ID"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unlike chirp ID error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	_, err = cfg.database.UnlikeChirp(r.Context(), database.UnlikeChirpParams{UserID: userID, ChirpID: chirpID})
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to unlike chirp"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unlike error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error unliking chirp: %s\n", err)
+		return
+	}
+	w.WriteHeader(204)
+}
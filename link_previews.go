@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"time"
+)
+
+// a preview that fails this many fetches in a row is marked failed until its cache entry goes stale
+const linkPreviewMaxAttempts = 3
+const linkPreviewBatchSize = 10
+
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// Fetches queued link previews until ctx ends. Previews are claimed with SKIP LOCKED so any number of instances can run this
+func (cfg *apiConfig) fetchLinkPreviewsJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for {
+			due, err := cfg.database.ClaimLinkPreviews(ctx, linkPreviewBatchSize)
+			if err != nil {
+				fmt.Printf("Failed to claim link previews: %s\n", err)
+				break
+			}
+			for _, row := range due {
+				cfg.fetchLinkPreview(ctx, row)
+			}
+			if len(due) < linkPreviewBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Fetches one preview and stores the result, failures are retried after 1, then 4 minutes before giving up
+func (cfg *apiConfig) fetchLinkPreview(ctx context.Context, row database.ClaimLinkPreviewsRow) {
+	preview, err := cfg.unfurl.Fetch(ctx, row.Url)
+	if err == nil {
+		err = cfg.database.SaveLinkPreview(ctx, database.SaveLinkPreviewParams{
+			Url:         row.Url,
+			Title:       preview.Title,
+			Description: preview.Description,
+			ImageUrl:    preview.ImageURL,
+			SiteName:    preview.SiteName,
+		})
+		if err != nil {
+			fmt.Printf("Failed to save link preview for %s: %s\n", row.Url, err)
+		}
+		return
+	}
+
+	attempts := int(row.Attempts) + 1
+	failed := database.FailLinkPreviewParams{
+		Url:         row.Url,
+		Status:      "pending",
+		LastError:   sql.NullString{String: err.Error(), Valid: true},
+		NextFetchAt: sql.NullTime{Time: time.Now().UTC().Add(time.Duration(attempts*attempts) * time.Minute), Valid: true},
+	}
+	if attempts >= linkPreviewMaxAttempts {
+		failed.Status = "failed"
+		failed.NextFetchAt = sql.NullTime{}
+	}
+	err = cfg.database.FailLinkPreview(ctx, failed)
+	if err != nil {
+		fmt.Printf("Failed to record link preview failure for %s: %s\n", row.Url, err)
+	}
+}
+
+// Looks up the fetched previews of each chirp's links, a URL linked twice in one chirp gets one preview
+func (cfg *apiConfig) chirpLinkPreviews(ctx context.Context, chirpIDs []uuid.UUID) (map[uuid.UUID][]LinkPreview, error) {
+	rows, err := cfg.database.GetChirpLinkPreviews(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	previews := make(map[uuid.UUID][]LinkPreview)
+	seen := make(map[uuid.UUID]map[string]bool)
+	for _, row := range rows {
+		if seen[row.ChirpID] == nil {
+			seen[row.ChirpID] = make(map[string]bool)
+		}
+		if seen[row.ChirpID][row.Url] {
+			continue
+		}
+		seen[row.ChirpID][row.Url] = true
+		previews[row.ChirpID] = append(previews[row.ChirpID], LinkPreview{
+			URL:         row.Url,
+			Title:       row.Title,
+			Description: row.Description,
+			ImageURL:    row.ImageUrl,
+			SiteName:    row.SiteName,
+		})
+	}
+	return previews, nil
+}
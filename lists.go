@@ -0,0 +1,492 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/pagination"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const maxListNameLength = 50
+
+// lists are private, only their owner sees them, their members and their feed
+type List struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	MemberCount int64     `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type ListMember struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	AddedAt     time.Time `json:"added_at"`
+}
+
+// Creates a named list for the authenticated user, names are unique per user
+func (cfg *apiConfig) createList(w http.ResponseWriter, r *http.Request) {
+	cfg.saveList(w, r, false)
+}
+
+// Renames one of the authenticated user's lists
+func (cfg *apiConfig) renameList(w http.ResponseWriter, r *http.Request) {
+	cfg.saveList(w, r, true)
+}
+
+// creating and renaming take the same body, rename picks which one runs
+func (cfg *apiConfig) saveList(w http.ResponseWriter, r *http.Request, rename bool) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	name := strings.TrimSpace(params.Name)
+	if err != nil || name == "" || len([]rune(name)) > maxListNameLength {
+		rtn := &returnErrors{Error: fmt.Sprintf("name must be 1 to %d characters", maxListNameLength)}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal list name error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	var row database.List
+	status := 201
+	if rename {
+		status = http.StatusOK
+		var listID uuid.UUID
+		listID, err = uuid.Parse(r.PathValue("listID"))
+		if err != nil {
+			err = sql.ErrNoRows
+		} else {
+			row, err = cfg.database.RenameList(r.Context(), database.RenameListParams{ID: listID, UserID: userID, Name: name})
+		}
+	} else {
+		row, err = cfg.database.CreateList(r.Context(), database.CreateListParams{UserID: userID, Name: name})
+	}
+	if err != nil {
+		//23505 is unique_violation, the user already has a list with this name
+		var pqErr *pq.Error
+		status := 503
+		rtn := &returnErrors{Error: "Failed to save list"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "List not found"
+		} else if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			status = 409
+			rtn.Error = "You already have a list with this name"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal save list error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	rtn := List{ID: row.ID, Name: row.Name, CreatedAt: row.CreatedAt, UpdatedAt: row.UpdatedAt}
+	if rename {
+		rtn.MemberCount, err = cfg.database.CountListMembers(r.Context(), row.ID)
+		if err != nil {
+			fmt.Printf("Error counting list members: %s\n", err)
+			w.WriteHeader(503)
+			return
+		}
+	}
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling list: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(dat)
+}
+
+// Lists the authenticated user's lists, newest first
+func (cfg *apiConfig) getLists(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	rows, err := cfg.database.GetLists(r.Context(), userID)
+	if err != nil {
+		fmt.Printf("Error querying lists: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	lists := make([]List, 0, len(rows))
+	for _, row := range rows {
+		lists = append(lists, List{
+			ID:          row.ID,
+			Name:        row.Name,
+			MemberCount: row.MemberCount,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+		})
+	}
+
+	dat, err := json.Marshal(lists)
+	if err != nil {
+		fmt.Printf("Error marshalling lists: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Deletes one of the authenticated user's lists, the users on it aren't affected
+func (cfg *apiConfig) deleteList(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	var deleted int64
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err == nil {
+		deleted, err = cfg.database.DeleteList(r.Context(), database.DeleteListParams{ID: listID, UserID: userID})
+	}
+	if err == nil && deleted == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to delete list"}
+		if errors.Is(err, sql.ErrNoRows) || listID == uuid.Nil {
+			status = 404
+			rtn.Error = "List not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal delete list error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// Adds the user with the handle in the path to one of the authenticated user's lists, adding someone twice is a no-op
+func (cfg *apiConfig) addListMember(w http.ResponseWriter, r *http.Request) {
+	cfg.changeListMember(w, r, true)
+}
+
+// Takes the user with the handle in the path off one of the authenticated user's lists
+func (cfg *apiConfig) removeListMember(w http.ResponseWriter, r *http.Request) {
+	cfg.changeListMember(w, r, false)
+}
+
+// adding and removing share their lookups, add picks which one runs
+func (cfg *apiConfig) changeListMember(w http.ResponseWriter, r *http.Request, add bool) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	list, err := cfg.ownedList(r, userID)
+	notFound := "List not found"
+	var memberID uuid.UUID
+	if err == nil {
+		notFound = "User not found"
+		memberID, err = cfg.database.GetUserIDByHandle(r.Context(), r.PathValue("handle"))
+	}
+	if err == nil && add {
+		err = cfg.database.AddListMember(r.Context(), database.AddListMemberParams{ListID: list.ID, UserID: memberID})
+	} else if err == nil {
+		err = cfg.database.RemoveListMember(r.Context(), database.RemoveListMemberParams{ListID: list.ID, UserID: memberID})
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to update list members"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = notFound
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal list member error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// Lists the users on one of the authenticated user's lists, most recently added first
+func (cfg *apiConfig) getListMembers(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	list, err := cfg.ownedList(r, userID)
+	var rows []database.GetListMembersRow
+	if err == nil {
+		rows, err = cfg.database.GetListMembers(r.Context(), list.ID)
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for list members"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "List not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal list members error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	members := make([]ListMember, 0, len(rows))
+	for _, row := range rows {
+		members = append(members, ListMember{
+			ID:          row.ID,
+			Handle:      row.Handle.String,
+			DisplayName: row.DisplayName,
+			Bio:         row.Bio,
+			IsChirpyRed: row.IsChirpyRed,
+			AddedAt:     row.AddedAt,
+		})
+	}
+	dat, err := json.Marshal(members)
+	if err != nil {
+		fmt.Printf("Error marshalling list members: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Feed of one of the authenticated user's lists, the chirps of everyone on it, newest first
+func (cfg *apiConfig) getListChirps(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Chirps     []Chirp `json:"chirps"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	list, err := cfg.ownedList(r, userID)
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for list"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "List not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal list lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	limit, err := pageLimit(r, 20, 100)
+	if err != nil {
+		rtn := &returnErrors{Error: "limit must be a positive number"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal list chirps limit error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	feedParams := database.GetListChirpsParams{ListID: list.ID, PageLimit: limit}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			rtn := &returnErrors{Error: "Invalid cursor"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal list chirps cursor error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+		feedParams.CursorCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		feedParams.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	rows, err := cfg.database.GetListChirps(r.Context(), feedParams)
+	if err != nil {
+		fmt.Printf("Error querying list chirps: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	chirps, err := cfg.chirpResponses(r.Context(), rows, userID)
+	if err != nil {
+		fmt.Printf("Error building list chirps: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	rtn := response{Chirps: chirps}
+	if len(rows) == int(limit) {
+		last := rows[len(rows)-1]
+		rtn.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling list chirps: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// looks up the list in the path, lists owned by someone else and malformed IDs come back as sql.ErrNoRows
+func (cfg *apiConfig) ownedList(r *http.Request, userID uuid.UUID) (database.List, error) {
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		return database.List{}, sql.ErrNoRows
+	}
+	return cfg.database.GetList(r.Context(), database.GetListParams{ID: listID, UserID: userID})
+}
@@ -1,19 +1,32 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/statusquonjc46/chirpy-http/internal/auth"
+	"github.com/statusquonjc46/chirpy-http/internal/broker"
 	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/entities"
+	"github.com/statusquonjc46/chirpy-http/internal/media"
+	"github.com/statusquonjc46/chirpy-http/internal/oidc"
+	"github.com/statusquonjc46/chirpy-http/internal/unfurl"
+	"github.com/statusquonjc46/chirpy-http/internal/webhooks"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -137,7 +150,14 @@ func (cfg *apiConfig) addUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	user, err := cfg.database.CreateUser(r.Context(), userParams)
 	if err != nil {
+		//23505 is unique_violation, the case insensitive email index caught a taken email
+		var pqErr *pq.Error
+		status := 500
 		rtn := &returnErrors{Error: "failed to add user to db"}
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			status = 409
+			rtn.Error = "Email is already in use"
+		}
 		dat, err := json.Marshal(rtn)
 		if err != nil {
 			fmt.Printf("Error marshalling json for adding user to DB: %s\n", err)
@@ -145,16 +165,21 @@ func (cfg *apiConfig) addUserHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(500)
+		w.WriteHeader(status)
 		w.Write(dat)
 		fmt.Printf("Error adding user to DB: %s\n", err)
 		return
 	}
 	ret := &User{
-		ID:        user.ID,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-		Email:     user.Email.String,
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email.String,
+		IsChirpyRed: user.IsChirpyRed,
+	}
+	err = enqueueWebhook(r.Context(), cfg.database, webhooks.UserCreated, user.ID, webhookUser{ID: user.ID, CreatedAt: user.CreatedAt, Email: user.Email.String})
+	if err != nil {
+		fmt.Printf("Error queueing user webhook: %s\n", err)
 	}
 
 	dat, err := json.Marshal(ret)
@@ -225,9 +250,7 @@ func (cfg *apiConfig) userLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	email := sql.NullString{String: params.Email, Valid: true}
-
-	getUser, err := cfg.database.UserandHashLookup(r.Context(), email)
+	getUser, err := cfg.database.UserandHashLookup(r.Context(), params.Email)
 	if err != nil {
 		rtn := &returnErrors{Error: "Incorrect email or password"}
 		dat, err := json.Marshal(rtn)
@@ -257,11 +280,78 @@ func (cfg *apiConfig) userLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if getUser.DeletedAt.Valid {
+		rtn := &returnErrors{Error: "Account is scheduled for deletion, restore it to log in"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal deleted account error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(403)
+		w.Write(dat)
+		return
+	}
+
+	suspended, err := cfg.database.IsSuspended(r.Context(), getUser.ID)
+	if err != nil || suspended {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for suspension"}
+		if err == nil {
+			status = 403
+			rtn.Error = "Account is suspended"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal suspended account error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	token, err := auth.MakeJWT(getUser.ID, cfg.jwtSecret, time.Hour)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to create access token"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal make JWT error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(500)
+		w.Write(dat)
+		return
+	}
+
+	refreshToken, err := cfg.createSession(r, getUser.ID)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to create session"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal create session error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		return
+	}
+
 	authedUser := &User{
-		ID:        getUser.ID,
-		CreatedAt: getUser.CreatedAt,
-		UpdatedAt: getUser.UpdatedAt,
-		Email:     getUser.Email.String,
+		ID:           getUser.ID,
+		CreatedAt:    getUser.CreatedAt,
+		UpdatedAt:    getUser.UpdatedAt,
+		Email:        getUser.Email.String,
+		IsChirpyRed:  getUser.IsChirpyRed,
+		Token:        token,
+		RefreshToken: refreshToken,
 	}
 
 	dat, err := json.Marshal(authedUser)
@@ -289,8 +379,11 @@ func (cfg *apiConfig) userLogin(w http.ResponseWriter, r *http.Request) {
 // validates chirp char lengths, censors banned words, then puts the full chirp in the chirp DB, and returns the full chirp
 func (cfg *apiConfig) addChirp(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Body   string `json:"body"`
-		UserID string `json:"user_id"`
+		Body        string          `json:"body"`
+		InReplyToID string          `json:"in_reply_to_id"`
+		QuoteOfID   string          `json:"quote_of_id"`
+		MediaIDs    []string        `json:"media_ids"`
+		Poll        *pollParameters `json:"poll"`
 	}
 	type returnErr struct {
 		Error string `json:"error"`
@@ -300,10 +393,26 @@ func (cfg *apiConfig) addChirp(w http.ResponseWriter, r *http.Request) {
 		UserID uuid.UUID
 	}
 
+	//the author is whoever the access token was issued to, replies, quotes and attachments are checked against them
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErr{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Error marshalling json for chirp auth error %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
 	//Decode POST data
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err = decoder.Decode(&params)
 	if err != nil {
 		rtn := &returnErr{Error: "something went wrong"}
 		dat, err := json.Marshal(rtn)
@@ -321,43 +430,112 @@ func (cfg *apiConfig) addChirp(w http.ResponseWriter, r *http.Request) {
 
 	//check for banned words, then return the cleaned string
 	strBody := params.Body
-	userID, err := uuid.Parse(params.UserID) //parse string to UUID
+
+	//replies must point at an existing chirp, they join the parent's conversation. Chirps by users blocked either way
+	//can't be replied to or quoted, they're reported as missing
+	var inReplyTo, conversationID uuid.NullUUID
+	if params.InReplyToID != "" {
+		parentID, err := uuid.Parse(params.InReplyToID)
+		if err == nil {
+			var parent database.Chirp
+			parent, err = cfg.visibleChirp(r.Context(), parentID, userID)
+			inReplyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+			conversationID = uuid.NullUUID{UUID: parent.ConversationID, Valid: true}
+		}
+		if err != nil {
+			rtn := &returnErr{Error: "chirp being replied to does not exist"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Error marshalling json for reply parent error %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+	}
+
+	//quotes reference an existing chirp, quoting a rechirp quotes the original
+	var quoteOf uuid.NullUUID
+	if params.QuoteOfID != "" {
+		quotedID, err := uuid.Parse(params.QuoteOfID)
+		if err == nil {
+			var quoted database.Chirp
+			quoted, err = cfg.visibleChirp(r.Context(), quotedID, userID)
+			if err == nil && quoted.RechirpOfID.Valid {
+				quoted, err = cfg.visibleChirp(r.Context(), quoted.RechirpOfID.UUID, userID)
+			}
+			quoteOf = uuid.NullUUID{UUID: quoted.ID, Valid: true}
+		}
+		if err != nil {
+			rtn := &returnErr{Error: "chirp being quoted does not exist"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Error marshalling json for quoted chirp error %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+	}
+
+	//attachments are uploaded first through POST /api/media, up to four can be attached
+	mediaIDs, err := cfg.chirpMediaIDs(r, userID, params.MediaIDs)
 	if err != nil {
-		rtn := &returnErr{Error: "unable to parse uuid from json POST"}
+		status := 503
+		rtn := &returnErr{Error: "Failed to query DB for media"}
+		if errors.Is(err, errInvalidChirpMedia) {
+			status = 400
+			rtn.Error = err.Error()
+		}
 		dat, err := json.Marshal(rtn)
 		if err != nil {
-			fmt.Printf("Error marshalling json for chirp parameters %s\n", err)
-			w.WriteHeader(400)
+			fmt.Printf("Error marshalling json for chirp media error %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	//polls are optional, they're checked here and created with the chirp
+	poll, err := validatePoll(params.Poll)
+	if err != nil {
+		rtn := &returnErr{Error: err.Error()}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Error marshalling json for chirp poll error %s\n", err)
+			w.WriteHeader(500)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(400)
 		w.Write(dat)
-		fmt.Printf("UserID was not able to be parses from string to UUID: %s\n", err)
 		return
 	}
 
 	//valid chirp logic
-	chirpLen := len(strBody) //get length of body to check if 140 chars
-	if chirpLen <= 140 {     //if less than or equal to 140, check for banned words, create a cleaned body
-		bannedWords := []string{"kerfuffle", "sharbert", "fornax"}
-		censor := "****"
-		cleanBody := strBody
-		for _, sub := range bannedWords {
-			uppedSub := strings.ToUpper(string(sub[0])) + sub[1:]
-			if strings.Contains(cleanBody, sub) {
-				cleanBody = strings.Replace(cleanBody, sub, censor, -1)
-			} else if strings.Contains(cleanBody, strings.ToUpper(sub)) {
-				cleanBody = strings.Replace(cleanBody, sub, censor, -1)
-			} else if strings.Contains(cleanBody, uppedSub) {
-				cleanBody = strings.Replace(cleanBody, uppedSub, censor, -1)
-			}
-		}
+	chirpLen := len(strBody)        //get length of body to check if 140 chars
+	if chirpLen <= maxChirpLength { //if less than or equal to 140, check for banned words, create a cleaned body
+		cleanBody := censorChirp(strBody)
 		fmt.Println(cleanBody)
 
 		//insert chirp to DB, save chirp to Chirp struct, r.context for ID, CreatedAt, UpdatedAt, cleanBody for cleanedbody
-		addChirpParams := database.AddChirpParams{Body: cleanBody, UserID: uuid.NullUUID{UUID: userID, Valid: true}}
-		createChirp, err := cfg.database.AddChirp(r.Context(), addChirpParams)
+		addChirpParams := database.AddChirpParams{
+			Body:           cleanBody,
+			UserID:         uuid.NullUUID{UUID: userID, Valid: true},
+			InReplyToID:    inReplyTo,
+			ConversationID: conversationID,
+			QuoteOfID:      quoteOf,
+		}
+		createChirp, err := cfg.createChirp(r.Context(), addChirpParams, mediaIDs, poll)
 		if err != nil {
 			rtn := &returnErr{Error: "Failed to Add Chirp to DB"}
 			dat, err := json.Marshal(rtn)
@@ -371,22 +549,12 @@ func (cfg *apiConfig) addChirp(w http.ResponseWriter, r *http.Request) {
 			w.Write(dat)
 			return
 		}
-		//get user_id from DB, check if Valid is true before setting user_id in chirp struct
-		var userID uuid.UUID
-		if createChirp.UserID.Valid {
-			userID = createChirp.UserID.UUID
-		} else {
-			userID = uuid.Nil
-			fmt.Printf("Error: user id is nil: %s", userID)
-		}
-
-		//create chirp instance with chirp data
-		chirp := &Chirp{
-			ID:        createChirp.ID,
-			CreatedAt: createChirp.CreatedAt,
-			UpdatedAt: createChirp.UpdatedAt,
-			Body:      createChirp.Body,
-			UserID:    userID,
+		//build the response chirp, this also looks up the author handle
+		chirp, err := cfg.announceChirp(r.Context(), createChirp)
+		if err != nil {
+			fmt.Printf("Error building chirp response: %s\n", err)
+			w.WriteHeader(500)
+			return
 		}
 		//marshal chirp, return the chirp, or return error
 		dat, err := json.Marshal(chirp)
@@ -401,7 +569,7 @@ func (cfg *apiConfig) addChirp(w http.ResponseWriter, r *http.Request) {
 		w.Write(dat)
 		fmt.Printf("Chirp added to DB successfully\nChirp: %s | Length: %d \n", strBody, chirpLen)
 	} else { //chirp length is too logn error response
-		overage := chirpLen - 140
+		overage := chirpLen - maxChirpLength
 		rtn := &returnErr{Error: "chirp is too long"}
 		dat, err := json.Marshal(rtn)
 		if err != nil {
@@ -418,13 +586,305 @@ func (cfg *apiConfig) addChirp(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+const maxChirpLength = 140
+
+// replaces banned words with ****, used for new chirps and edits alike
+func censorChirp(body string) string {
+	bannedWords := []string{"kerfuffle", "sharbert", "fornax"}
+	censor := "****"
+	cleanBody := body
+	for _, sub := range bannedWords {
+		uppedSub := strings.ToUpper(string(sub[0])) + sub[1:]
+		if strings.Contains(cleanBody, sub) {
+			cleanBody = strings.Replace(cleanBody, sub, censor, -1)
+		} else if strings.Contains(cleanBody, strings.ToUpper(sub)) {
+			cleanBody = strings.Replace(cleanBody, sub, censor, -1)
+		} else if strings.Contains(cleanBody, uppedSub) {
+			cleanBody = strings.Replace(cleanBody, uppedSub, censor, -1)
+		}
+	}
+	return cleanBody
+}
+
+// Inserts the chirp, attaches its media and poll and indexes its entities in one transaction, then publishes the notifications it caused
+func (cfg *apiConfig) createChirp(ctx context.Context, params database.AddChirpParams, mediaIDs []uuid.UUID, poll *newPoll) (database.Chirp, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+
+	chirp, err := cfg.insertChirp(ctx, cfg.database.WithTx(tx), params, mediaIDs, poll)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return chirp, tx.Commit()
+}
+
+// does createChirp's work inside a transaction the caller owns, scheduled drafts are published this way
+func (cfg *apiConfig) insertChirp(ctx context.Context, qtx *database.Queries, params database.AddChirpParams, mediaIDs []uuid.UUID, poll *newPoll) (database.Chirp, error) {
+	chirp, err := qtx.AddChirp(ctx, params)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	for i, mediaID := range mediaIDs {
+		err = qtx.AttachChirpMedia(ctx, database.AttachChirpMediaParams{ChirpID: chirp.ID, MediaID: mediaID, Position: int32(i)})
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+
+	if poll != nil {
+		err = insertPoll(ctx, qtx, chirp.ID, poll)
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+
+	err = indexChirpEntities(ctx, qtx, chirp)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	//replies and mentions notify other users through triggers, read them back and queue their events before committing
+	notifications, err := qtx.GetNewNotifications(ctx, params.UserID.UUID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	err = cfg.publishNotifications(ctx, qtx, notifications)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return chirp, nil
+}
+
+// Builds the response for a chirp that was just committed and sends it to streams and webhook endpoints
+func (cfg *apiConfig) announceChirp(ctx context.Context, row database.Chirp) (Chirp, error) {
+	chirps, err := cfg.chirpResponses(ctx, []database.Chirp{row}, row.UserID.UUID)
+	if err != nil {
+		return Chirp{}, err
+	}
+	chirp := chirps[0]
+	cfg.publishChirp(ctx, chirp)
+	err = enqueueWebhook(ctx, cfg.database, webhooks.ChirpCreated, row.UserID.UUID, chirp)
+	if err != nil {
+		fmt.Printf("Error queueing chirp webhook: %s\n", err)
+	}
+	return chirp, nil
+}
+
+// Indexes the hashtags, links and mentions in the chirp's body, mentions notify the mentioned users through a trigger
+func indexChirpEntities(ctx context.Context, qtx *database.Queries, chirp database.Chirp) error {
+	ents := entities.Extract(chirp.Body)
+	for _, tag := range ents.Hashtags {
+		hashtag, err := qtx.UpsertHashtag(ctx, tag.Tag)
+		if err != nil {
+			return err
+		}
+		hashtagParams := database.AddChirpHashtagParams{
+			ChirpID:     chirp.ID,
+			HashtagID:   hashtag.ID,
+			StartOffset: int32(tag.Start),
+			EndOffset:   int32(tag.End),
+		}
+		err = qtx.AddChirpHashtag(ctx, hashtagParams)
+		if err != nil {
+			return err
+		}
+	}
+	//previews are fetched in the background by fetchLinkPreviewsJob, chirps show them once they're ready
+	for _, link := range ents.URLs {
+		err := qtx.QueueLinkPreview(ctx, link.URL)
+		if err != nil {
+			return err
+		}
+		linkParams := database.AddChirpLinkParams{
+			ChirpID:     chirp.ID,
+			Url:         link.URL,
+			StartOffset: int32(link.Start),
+			EndOffset:   int32(link.End),
+		}
+		err = qtx.AddChirpLink(ctx, linkParams)
+		if err != nil {
+			return err
+		}
+	}
+	for _, mention := range ents.Mentions {
+		mentionParams := database.AddChirpMentionParams{
+			ChirpID:     chirp.ID,
+			Handle:      mention.Handle,
+			StartOffset: int32(mention.Start),
+			EndOffset:   int32(mention.End),
+		}
+		err := qtx.AddChirpMention(ctx, mentionParams)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Builds the API Chirp for each DB row, data that lives outside the chirps table is looked up in batches.
+// viewerID is the authenticated user making the request or uuid.Nil for anonymous requests.
+// Chirps by users the viewer blocked or was blocked by are left out of the result, as are rechirps whose original can
+// no longer be shown
+func (cfg *apiConfig) chirpResponses(ctx context.Context, rows []database.Chirp, viewerID uuid.UUID) ([]Chirp, error) {
+	return cfg.buildChirps(ctx, rows, viewerID, true)
+}
+
+// embedded chirps are built with embed false so quotes of quotes only go one level deep
+func (cfg *apiConfig) buildChirps(ctx context.Context, rows []database.Chirp, viewerID uuid.UUID, embed bool) ([]Chirp, error) {
+	authorIDs := make([]uuid.UUID, 0, len(rows))
+	chirpIDs := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		chirpIDs = append(chirpIDs, row.ID)
+		if row.UserID.Valid {
+			authorIDs = append(authorIDs, row.UserID.UUID)
+		}
+	}
+
+	handleRows, err := cfg.database.GetUserHandles(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+	handles := make(map[uuid.UUID]string, len(handleRows))
+	for _, h := range handleRows {
+		handles[h.ID] = h.Handle.String
+	}
+
+	var liked map[uuid.UUID]bool
+	if viewerID != uuid.Nil {
+		likedIDs, err := cfg.database.GetLikedChirpIDs(ctx, database.GetLikedChirpIDsParams{UserID: viewerID, ChirpIds: chirpIDs})
+		if err != nil {
+			return nil, err
+		}
+		liked = make(map[uuid.UUID]bool, len(likedIDs))
+		for _, id := range likedIDs {
+			liked[id] = true
+		}
+	}
+
+	mediaRows, err := cfg.database.GetChirpMedia(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	attachments := make(map[uuid.UUID][]Media)
+	for _, m := range mediaRows {
+		attachments[m.ChirpID] = append(attachments[m.ChirpID], mediaResponse(database.Medium{
+			ID:          m.ID,
+			ContentType: m.ContentType,
+			Width:       m.Width,
+			Height:      m.Height,
+		}))
+	}
+
+	previews, err := cfg.chirpLinkPreviews(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	polls, err := cfg.chirpPolls(ctx, chirpIDs, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	blocked, err := cfg.blockedUserIDs(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var referenced map[uuid.UUID]Chirp
+	if embed {
+		refIDs := []uuid.UUID{}
+		for _, row := range rows {
+			if row.RechirpOfID.Valid {
+				refIDs = append(refIDs, row.RechirpOfID.UUID)
+			}
+			if row.QuoteOfID.Valid {
+				refIDs = append(refIDs, row.QuoteOfID.UUID)
+			}
+		}
+		if len(refIDs) > 0 {
+			refRows, err := cfg.database.GetChirpsByIDs(ctx, refIDs)
+			if err != nil {
+				return nil, err
+			}
+			refChirps, err := cfg.buildChirps(ctx, refRows, viewerID, false)
+			if err != nil {
+				return nil, err
+			}
+			referenced = make(map[uuid.UUID]Chirp, len(refChirps))
+			for _, ref := range refChirps {
+				referenced[ref.ID] = ref
+			}
+		}
+	}
+
+	chirps := make([]Chirp, 0, len(rows))
+	for _, row := range rows {
+		if blocked[row.UserID.UUID] {
+			continue
+		}
+		ch := Chirp{
+			ID:             row.ID,
+			CreatedAt:      row.CreatedAt,
+			UpdatedAt:      row.UpdatedAt,
+			Body:           row.Body,
+			UserID:         row.UserID.UUID,
+			AuthorHandle:   handles[row.UserID.UUID],
+			Entities:       entities.Extract(row.Body),
+			ConversationID: row.ConversationID,
+			LikeCount:      row.LikeCount,
+			Media:          attachments[row.ID],
+			LinkPreviews:   previews[row.ID],
+			Poll:           polls[row.ID],
+		}
+		if liked != nil {
+			likedByMe := liked[row.ID]
+			ch.LikedByMe = &likedByMe
+		}
+		if row.InReplyToID.Valid {
+			parentID := row.InReplyToID.UUID
+			ch.InReplyToID = &parentID
+		}
+		if row.RechirpOfID.Valid {
+			originalID := row.RechirpOfID.UUID
+			ch.RechirpOfID = &originalID
+			if embed {
+				original, ok := referenced[originalID]
+				if !ok {
+					continue
+				}
+				ch.RechirpedChirp = &original
+			}
+		}
+		if row.QuoteOfID.Valid {
+			quotedID := row.QuoteOfID.UUID
+			ch.QuoteOfID = &quotedID
+		}
+		if row.IsQuote && embed {
+			//quote_of_id is nulled when the original is deleted and originals by deleted accounts aren't loaded, the quote itself stays up
+			if quoted, ok := referenced[row.QuoteOfID.UUID]; ok && row.QuoteOfID.Valid {
+				ch.QuotedChirp = &quoted
+			} else {
+				ch.QuoteRemoved = true
+			}
+		}
+		chirps = append(chirps, ch)
+	}
+	return chirps, nil
+}
+
 // Get all Chirps from chirps table, return the array of chirps
 func (cfg *apiConfig) getAllChirps(w http.ResponseWriter, r *http.Request) {
 	type returnErrors struct {
 		Error string `json:"error"`
 	}
 
-	allChirps, err := cfg.database.GetAllChirps(r.Context())
+	//the viewer is optional, anonymous requests see everyone and just don't get liked_by_me
+	viewerID, _ := cfg.userIDFromRequest(r)
+	allChirps, err := cfg.database.GetAllChirps(r.Context(), uuid.NullUUID{UUID: viewerID, Valid: viewerID != uuid.Nil})
 	if err != nil {
 		rtn := &returnErrors{Error: "Failed to query DB for all chirps"}
 		dat, err := json.Marshal(rtn)
@@ -439,26 +899,19 @@ func (cfg *apiConfig) getAllChirps(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var jsonFormattedChirps []Chirp
-	for _, row := range allChirps {
-
-		var userID uuid.UUID
-		if row.UserID.Valid {
-			userID = row.UserID.UUID
-		} else {
-			fmt.Printf("Error: user id is nil: %s", userID)
-			continue
-		}
-
-		ch := Chirp{
-			ID:        row.ID,
-			CreatedAt: row.CreatedAt,
-			UpdatedAt: row.UpdatedAt,
-			Body:      row.Body,
-			UserID:    userID,
+	jsonFormattedChirps, err := cfg.chirpResponses(r.Context(), allChirps, viewerID)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to query DB for chirp authors"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal chirp author query error: %s", err)
+			w.WriteHeader(500)
+			return
 		}
-
-		jsonFormattedChirps = append(jsonFormattedChirps, ch)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		return
 	}
 
 	returnChirp, err := json.Marshal(jsonFormattedChirps)
@@ -516,19 +969,36 @@ func (cfg *apiConfig) getSpecificChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var userID uuid.UUID
-	if chirpAtID.UserID.Valid {
-		userID = chirpAtID.UserID.UUID
-	} else {
-		fmt.Printf("Error: user id is nil: %s\n", userID)
+	viewerID, _ := cfg.userIDFromRequest(r)
+	chirps, err := cfg.chirpResponses(r.Context(), []database.Chirp{chirpAtID}, viewerID)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to query DB for chirp author"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal chirp author query error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		return
 	}
-	ch := Chirp{
-		ID:        chirpAtID.ID,
-		CreatedAt: chirpAtID.CreatedAt,
-		UpdatedAt: chirpAtID.UpdatedAt,
-		Body:      chirpAtID.Body,
-		UserID:    userID,
+	//a rechirp of a chirp that can no longer be shown is hidden along with it
+	if len(chirps) == 0 {
+		rtn := &returnErrors{Error: "Chirp not found"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal hidden rechirp error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(404)
+		w.Write(dat)
+		return
 	}
+	ch := chirps[0]
 
 	returnChirp, err := json.Marshal(ch)
 	if err != nil {
@@ -550,6 +1020,104 @@ func (cfg *apiConfig) getSpecificChirp(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("%+v", ch)
 }
 
+// Deletes one of the authenticated user's chirps, rechirps of it go with it and quotes of it show as removed
+func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	//an unparseable ID can't match a chirp, it's reported the same as a missing one
+	chirp := database.Chirp{}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		err = sql.ErrNoRows
+	} else {
+		chirp, err = cfg.database.GetSpecificChirp(r.Context(), chirpID)
+	}
+	if err != nil || chirp.UserID.UUID != userID {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for chirp"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "Chirp not found"
+		} else if err == nil {
+			status = 403
+			rtn.Error = "You can only delete your own chirps"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal delete chirp lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	err = cfg.removeChirp(r.Context(), chirp)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to delete chirp"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal delete chirp error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error deleting chirp: %s\n", err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// Deletes the chirp and queues its chirp.deleted webhooks in one transaction
+func (cfg *apiConfig) removeChirp(ctx context.Context, chirp database.Chirp) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	err = discardChirp(ctx, qtx, chirp)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Deletes the chirp and queues its chirp.deleted webhooks using the caller's transaction
+func discardChirp(ctx context.Context, qtx *database.Queries, chirp database.Chirp) error {
+	deleted, err := qtx.DeleteChirp(ctx, database.DeleteChirpParams{ID: chirp.ID, UserID: chirp.UserID})
+	if err != nil {
+		return err
+	}
+	//already gone, there's nothing to announce
+	if deleted == 0 {
+		return nil
+	}
+	return enqueueWebhook(ctx, qtx, webhooks.ChirpDeleted, chirp.UserID.UUID, webhookDeletedChirp{ID: chirp.ID, UserID: chirp.UserID.UUID})
+}
+
 // MIDDLEWARE
 // middleware to do the actual counting of site visits
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -559,11 +1127,71 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	})
 }
 
+// AUTH HELPERS
+// validates the bearer JWT on the request and returns the user it was issued to. Tokens of deleted and suspended
+// users are turned away here so deleting or suspending an account takes effect at once rather than when the token expires
+func (cfg *apiConfig) userIDFromRequest(r *http.Request) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	//browsers can't set headers on a websocket upgrade, so only those may send the token as ?access_token=
+	if err != nil && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		token, err = r.URL.Query().Get("access_token"), nil
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	status, err := cfg.database.GetAccountStatus(r.Context(), userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if status.Deleted {
+		return uuid.Nil, errAccountDeleted
+	}
+	if status.Suspended {
+		return uuid.Nil, errAccountSuspended
+	}
+	return userID, nil
+}
+
+// reads the ?limit= query parameter, falling back to def and capping at max
+func pageLimit(r *http.Request, def, max int32) (int32, error) {
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("invalid limit: %q", limitStr)
+	}
+	if limit > int(max) {
+		return max, nil
+	}
+	return int32(limit), nil
+}
+
+// how long shutdown waits for in flight requests and websockets
+const shutdownTimeout = 10 * time.Second
+
 // struct for api site hits
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	database       *database.Queries
-	platform       string
+	fileserverHits        atomic.Int32
+	db                    *sql.DB
+	database              *database.Queries
+	platform              string
+	jwtSecret             string
+	paymentsAPIKey        string
+	paymentsWebhookSecret string
+	adminAPIKey           string
+	oidc                  *oidc.Provider
+	media                 media.Storage
+	unfurl                *unfurl.Fetcher
+	chirpEditWindow       time.Duration
+	events                *broker.Broker
+	serverCtx             context.Context
+	wsConns               sync.WaitGroup
 }
 
 type User struct {
@@ -572,14 +1200,34 @@ type User struct {
 	UpdatedAt      time.Time `json:"updated_at"`
 	Email          string    `json:"email"`
 	HashedPassword string    `json:"hashed_password"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	Handle         string    `json:"handle,omitempty"`
+	DisplayName    string    `json:"display_name,omitempty"`
+	Bio            string    `json:"bio,omitempty"`
+	Token          string    `json:"token,omitempty"`
+	RefreshToken   string    `json:"refresh_token,omitempty"`
 }
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID             uuid.UUID         `json:"id"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	Body           string            `json:"body"`
+	UserID         uuid.UUID         `json:"user_id"`
+	AuthorHandle   string            `json:"author_handle,omitempty"`
+	Entities       entities.Entities `json:"entities"`
+	InReplyToID    *uuid.UUID        `json:"in_reply_to_id,omitempty"`
+	ConversationID uuid.UUID         `json:"conversation_id"`
+	LikeCount      int32             `json:"like_count"`
+	LikedByMe      *bool             `json:"liked_by_me,omitempty"`
+	RechirpOfID    *uuid.UUID        `json:"rechirp_of_id,omitempty"`
+	RechirpedChirp *Chirp            `json:"rechirped_chirp,omitempty"`
+	QuoteOfID      *uuid.UUID        `json:"quote_of_id,omitempty"`
+	QuotedChirp    *Chirp            `json:"quoted_chirp,omitempty"`
+	QuoteRemoved   bool              `json:"quote_removed,omitempty"`
+	Media          []Media           `json:"media,omitempty"`
+	LinkPreviews   []LinkPreview     `json:"link_previews,omitempty"`
+	Poll           *Poll             `json:"poll,omitempty"`
 }
 
 func main() {
@@ -589,18 +1237,59 @@ func main() {
 		log.Fatal(err)
 	}
 
+	//every request context derives from serverCtx, it's cancelled when shutdown starts so long lived streams end
+	serverCtx, stopServer := context.WithCancel(context.Background())
 	mux := http.NewServeMux() //instantiate the server mux
 	server := &http.Server{   //create the http server
-		Addr:    ":8080",
-		Handler: mux,
+		Addr:        ":8080",
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return serverCtx },
 	}
+	server.RegisterOnShutdown(stopServer)
 
-	cfg := &apiConfig{} //instantiate an instance of apiConfig struct
+	cfg := &apiConfig{serverCtx: serverCtx} //instantiate an instance of apiConfig struct
 	dbURL := os.Getenv("DB_URL")
 	cfg.platform = os.Getenv("PLATFORM")
+	cfg.jwtSecret = os.Getenv("JWT_SECRET")
+	cfg.paymentsAPIKey = os.Getenv("PAYMENTS_API_KEY")
+	cfg.paymentsWebhookSecret = os.Getenv("PAYMENTS_WEBHOOK_SECRET")
+	cfg.adminAPIKey = os.Getenv("ADMIN_API_KEY")
+	cfg.chirpEditWindow = defaultChirpEditWindow
+	if window := os.Getenv("CHIRP_EDIT_WINDOW"); window != "" {
+		cfg.chirpEditWindow, err = time.ParseDuration(window)
+		if err != nil {
+			log.Fatalf("Invalid CHIRP_EDIT_WINDOW: %s", err)
+		}
+	}
 	db, err := sql.Open("postgres", dbURL)
 	dbQueries := database.New(db)
+	cfg.db = db
 	cfg.database = dbQueries
+	cfg.events = broker.New(eventReplaySize, eventSubscriberBuffer)
+	cfg.unfurl = unfurl.NewFetcher()
+
+	//media blobs go to an S3 compatible bucket when one is configured, otherwise to MEDIA_DIR on local disk
+	if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" {
+		cfg.media = media.NewS3Storage(endpoint, os.Getenv("S3_BUCKET"), os.Getenv("S3_REGION"), os.Getenv("S3_ACCESS_KEY_ID"), os.Getenv("S3_SECRET_ACCESS_KEY"))
+	} else {
+		mediaDir := os.Getenv("MEDIA_DIR")
+		if mediaDir == "" {
+			mediaDir = "media"
+		}
+		cfg.media = media.NewLocalStorage(mediaDir)
+	}
+
+	//external login is optional, only enabled when an issuer is configured
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		provider, err := oidc.Discover(ctx, issuer, os.Getenv("OIDC_CLIENT_ID"), os.Getenv("OIDC_CLIENT_SECRET"), os.Getenv("OIDC_REDIRECT_URL"))
+		cancel()
+		if err != nil {
+			fmt.Printf("OIDC discovery failed, external login disabled: %s\n", err)
+		} else {
+			cfg.oidc = provider
+		}
+	}
 
 	fmt.Printf("Attempting to serve at: %s\n", server.Addr)
 
@@ -613,11 +1302,126 @@ func main() {
 	mux.HandleFunc("POST /api/users", cfg.addUserHandler)
 	mux.HandleFunc("GET /api/chirps", cfg.getAllChirps)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.getSpecificChirp)
+	mux.HandleFunc("PATCH /api/chirps/{chirpID}", cfg.editChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.deleteChirp)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", cfg.getChirpRevisions)
+	mux.HandleFunc("POST /api/drafts", cfg.createDraft)
+	mux.HandleFunc("GET /api/drafts", cfg.getDrafts)
+	mux.HandleFunc("GET /api/drafts/{draftID}", cfg.getDraft)
+	mux.HandleFunc("PUT /api/drafts/{draftID}", cfg.updateDraft)
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", cfg.deleteDraft)
+	mux.HandleFunc("POST /api/drafts/{draftID}/publish", cfg.publishDraft)
+	mux.HandleFunc("POST /api/media", cfg.uploadMedia)
+	mux.HandleFunc("GET /api/media/{mediaID}", cfg.getMediaFile)
+	mux.HandleFunc("GET /api/media/{mediaID}/thumbnail", cfg.getMediaFile)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", cfg.getChirpThread)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/votes", cfg.votePoll)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmarks", cfg.bookmarkChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmarks", cfg.removeBookmark)
+	mux.HandleFunc("GET /api/bookmarks", cfg.getBookmarks)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", cfg.likeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", cfg.unlikeChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirps", cfg.rechirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirps", cfg.undoRechirp)
 	mux.HandleFunc("POST /api/login", cfg.userLogin)
+	mux.HandleFunc("GET /api/auth/oidc/login", cfg.oidcLogin)
+	mux.HandleFunc("GET /api/auth/oidc/callback", cfg.oidcCallback)
+	mux.HandleFunc("POST /api/refresh", cfg.refreshHandler)
+	mux.HandleFunc("POST /api/revoke", cfg.revokeHandler)
+	mux.HandleFunc("GET /api/sessions", cfg.listSessions)
+	mux.HandleFunc("DELETE /api/sessions", cfg.revokeAllSessions)
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", cfg.revokeSession)
+	mux.HandleFunc("DELETE /api/users/me", cfg.deleteAccount)
+	mux.HandleFunc("POST /api/users/restore", cfg.restoreAccount)
+	mux.HandleFunc("POST /api/webhooks/payments", cfg.paymentsWebhook)
+	mux.HandleFunc("POST /api/webhooks/endpoints", cfg.createWebhookEndpoint)
+	mux.HandleFunc("GET /api/webhooks/endpoints", cfg.getWebhookEndpoints)
+	mux.HandleFunc("DELETE /api/webhooks/endpoints/{endpointID}", cfg.deleteWebhookEndpoint)
+	mux.HandleFunc("GET /api/webhooks/dead-letters", cfg.getWebhookDeadLetters)
+	mux.HandleFunc("POST /api/webhooks/deliveries/{deliveryID}/redeliver", cfg.redeliverWebhook)
+	mux.HandleFunc("GET /api/search/chirps", cfg.searchChirps)
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", cfg.getHashtagChirps)
+	mux.HandleFunc("PUT /api/users/me", cfg.updateProfile)
+	mux.HandleFunc("GET /api/users/{handle}", cfg.getPublicProfile)
+	mux.HandleFunc("POST /api/users/{handle}/follow", cfg.followUser)
+	mux.HandleFunc("DELETE /api/users/{handle}/follow", cfg.unfollowUser)
+	mux.HandleFunc("GET /api/users/{handle}/followers", cfg.getFollowers)
+	mux.HandleFunc("GET /api/users/{handle}/following", cfg.getFollowing)
+	mux.HandleFunc("POST /api/users/{handle}/block", cfg.blockUser)
+	mux.HandleFunc("DELETE /api/users/{handle}/block", cfg.unblockUser)
+	mux.HandleFunc("POST /api/users/{handle}/mute", cfg.muteUser)
+	mux.HandleFunc("DELETE /api/users/{handle}/mute", cfg.unmuteUser)
+	mux.HandleFunc("GET /api/blocks", cfg.getBlocks)
+	mux.HandleFunc("GET /api/mutes", cfg.getMutes)
+	mux.HandleFunc("POST /api/conversations", cfg.startConversation)
+	mux.HandleFunc("GET /api/conversations", cfg.getConversations)
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", cfg.getMessages)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", cfg.sendMessage)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", cfg.markConversationRead)
+	mux.HandleFunc("POST /api/reports", cfg.createReport)
+	mux.HandleFunc("GET /api/admin/reports", cfg.getReports)
+	mux.HandleFunc("POST /api/admin/reports/{reportID}/actions", cfg.actOnReport)
+	mux.HandleFunc("GET /api/admin/moderation-actions", cfg.getModerationActions)
+	mux.HandleFunc("POST /api/admin/moderators/{handle}", cfg.addModerator)
+	mux.HandleFunc("DELETE /api/admin/moderators/{handle}", cfg.removeModerator)
+	mux.HandleFunc("GET /api/timeline", cfg.getTimeline)
+	mux.HandleFunc("POST /api/lists", cfg.createList)
+	mux.HandleFunc("GET /api/lists", cfg.getLists)
+	mux.HandleFunc("PUT /api/lists/{listID}", cfg.renameList)
+	mux.HandleFunc("DELETE /api/lists/{listID}", cfg.deleteList)
+	mux.HandleFunc("GET /api/lists/{listID}/members", cfg.getListMembers)
+	mux.HandleFunc("POST /api/lists/{listID}/members/{handle}", cfg.addListMember)
+	mux.HandleFunc("DELETE /api/lists/{listID}/members/{handle}", cfg.removeListMember)
+	mux.HandleFunc("GET /api/lists/{listID}/chirps", cfg.getListChirps)
+	mux.HandleFunc("GET /api/stream/chirps", cfg.streamChirps)
+	mux.HandleFunc("GET /api/ws", cfg.websocketHandler)
+	mux.HandleFunc("GET /api/notifications", cfg.getNotifications)
+	mux.HandleFunc("POST /api/notifications/read", cfg.markNotificationsRead)
+	mux.HandleFunc("GET /api/notifications/preferences", cfg.getNotificationPreferences)
+	mux.HandleFunc("PUT /api/notifications/preferences", cfg.updateNotificationPreferences)
+
+	//events written before this point aren't delivered, starting from 0 instead would replay every retained event
+	lastEventID, err := cfg.database.GetLatestEventID(serverCtx)
+	if err != nil {
+		log.Fatalf("Failed to read latest event id: %s", err)
+	}
+
+	//background jobs
+	go cfg.purgeDeletedUsersJob(serverCtx, time.Hour)
+	go cfg.pruneEventsJob(serverCtx, time.Hour)
+	go cfg.listenForEvents(serverCtx, dbURL, lastEventID)
+	go cfg.deliverWebhooksJob(serverCtx, 5*time.Second)
+	go cfg.fetchLinkPreviewsJob(serverCtx, 5*time.Second)
+	go cfg.publishScheduledChirpsJob(serverCtx, 5*time.Second)
 
 	//Serve content on connection
-	err = server.ListenAndServe()
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed at ListenAndServe: %s", err)
+		}
+	}()
+
+	//on SIGINT/SIGTERM stop accepting requests, end streams and close websockets with a going away frame
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	fmt.Println("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = server.Shutdown(ctx)
 	if err != nil {
-		fmt.Printf("Failed at ListenAndServe: %s", err)
+		fmt.Printf("Error shutting down server: %s\n", err)
+	}
+	//Shutdown doesn't track hijacked connections, wait for the websocket handlers separately
+	wsDone := make(chan struct{})
+	go func() {
+		cfg.wsConns.Wait()
+		close(wsDone)
+	}()
+	select {
+	case <-wsDone:
+	case <-ctx.Done():
+		fmt.Println("Timed out waiting for websockets to close")
 	}
 }
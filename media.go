@@ -0,0 +1,246 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/media"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// a chirp can carry this many attachments
+const maxChirpMedia = 4
+
+var errInvalidChirpMedia = errors.New("invalid media_ids")
+
+type Media struct {
+	ID           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+}
+
+// Accepts a multipart upload with the image in the "file" field. The type is sniffed from the bytes, metadata is stripped
+// by re-encoding and a thumbnail is stored next to it. The returned ID goes in media_ids when posting a chirp
+func (cfg *apiConfig) uploadMedia(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	//leave room for the multipart headers around the file
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxUploadSize+64<<10)
+	var data []byte
+	file, _, err := r.FormFile("file")
+	if err == nil {
+		data, err = io.ReadAll(io.LimitReader(file, media.MaxUploadSize+1))
+		file.Close()
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) || len(data) > media.MaxUploadSize {
+		rtn := &returnErrors{Error: fmt.Sprintf("File is larger than %d MB", media.MaxUploadSize>>20)}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal upload size error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(413)
+		w.Write(dat)
+		return
+	}
+	if err != nil {
+		rtn := &returnErrors{Error: "Request must be multipart/form-data with a file field"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal upload form error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	img, err := media.Process(data)
+	if err != nil {
+		status := 400
+		rtn := &returnErrors{Error: "File is not a readable image"}
+		if errors.Is(err, media.ErrUnsupportedType) {
+			status = 415
+			rtn.Error = err.Error()
+		} else if errors.Is(err, media.ErrTooLarge) {
+			rtn.Error = err.Error()
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal image processing error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	mediaID := uuid.New()
+	ext := strings.TrimPrefix(img.ContentType, "image/")
+	storageKey := "media/" + mediaID.String() + "." + ext
+	thumbnailKey := "media/" + mediaID.String() + "_thumb." + ext
+	err = cfg.media.Put(r.Context(), storageKey, img.ContentType, img.Data)
+	if err == nil {
+		err = cfg.media.Put(r.Context(), thumbnailKey, img.ContentType, img.Thumbnail)
+	}
+	var row database.Medium
+	if err == nil {
+		row, err = cfg.database.CreateMedia(r.Context(), database.CreateMediaParams{
+			ID:           mediaID,
+			UserID:       userID,
+			ContentType:  img.ContentType,
+			Width:        int32(img.Width),
+			Height:       int32(img.Height),
+			SizeBytes:    int32(len(img.Data)),
+			StorageKey:   storageKey,
+			ThumbnailKey: thumbnailKey,
+		})
+	}
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to store media"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal media storage error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error storing media: %s\n", err)
+		return
+	}
+
+	dat, err := json.Marshal(mediaResponse(row))
+	if err != nil {
+		fmt.Printf("Error marshalling media: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(dat)
+}
+
+// Serves an uploaded image, GET /api/media/{mediaID}/thumbnail serves its thumbnail. Blobs never change once stored
+func (cfg *apiConfig) getMediaFile(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	var row database.Medium
+	mediaID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		err = sql.ErrNoRows
+	} else {
+		row, err = cfg.database.GetMedia(r.Context(), mediaID)
+	}
+	key := row.StorageKey
+	if strings.HasSuffix(r.URL.Path, "/thumbnail") {
+		key = row.ThumbnailKey
+	}
+	var blob io.ReadCloser
+	if err == nil {
+		blob, err = cfg.media.Get(r.Context(), key)
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to load media"}
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, media.ErrNotFound) {
+			status = 404
+			rtn.Error = "Media not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal media lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", row.ContentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, blob)
+}
+
+// Parses the media_ids of a new chirp and checks they're the author's own uploads that aren't on another chirp yet.
+// Problems with the IDs themselves wrap errInvalidChirpMedia
+func (cfg *apiConfig) chirpMediaIDs(r *http.Request, userID uuid.UUID, ids []string) ([]uuid.UUID, error) {
+	if len(ids) > maxChirpMedia {
+		return nil, fmt.Errorf("%w: a chirp can have at most %d attachments", errInvalidChirpMedia, maxChirpMedia)
+	}
+	mediaIDs := make([]uuid.UUID, 0, len(ids))
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		mediaID, err := uuid.Parse(id)
+		if err != nil || seen[mediaID] {
+			return nil, fmt.Errorf("%w: each must be a distinct media ID", errInvalidChirpMedia)
+		}
+		seen[mediaID] = true
+		mediaIDs = append(mediaIDs, mediaID)
+	}
+	if len(mediaIDs) == 0 {
+		return nil, nil
+	}
+	count, err := cfg.database.CountAttachableMedia(r.Context(), database.CountAttachableMediaParams{
+		UserID:   userID,
+		MediaIds: mediaIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if count != int64(len(mediaIDs)) {
+		return nil, fmt.Errorf("%w: media must be your own uploads that aren't attached to another chirp", errInvalidChirpMedia)
+	}
+	return mediaIDs, nil
+}
+
+func mediaResponse(row database.Medium) Media {
+	return Media{
+		ID:           row.ID,
+		ContentType:  row.ContentType,
+		Width:        row.Width,
+		Height:       row.Height,
+		URL:          "/api/media/" + row.ID.String(),
+		ThumbnailURL: "/api/media/" + row.ID.String() + "/thumbnail",
+	}
+}
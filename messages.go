@@ -0,0 +1,656 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/pagination"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const maxMessageLength = 1000
+
+// members of a conversation including whoever started it
+const maxConversationMembers = 10
+
+// UnreadCount is only filled in when listing conversations
+type Conversation struct {
+	ID            uuid.UUID            `json:"id"`
+	Members       []ConversationMember `json:"members"`
+	CreatedAt     time.Time            `json:"created_at"`
+	LastMessageAt time.Time            `json:"last_message_at"`
+	UnreadCount   *int64               `json:"unread_count,omitempty"`
+}
+
+type ConversationMember struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+}
+
+type Message struct {
+	ID             uuid.UUID `json:"id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Starts a conversation between the authenticated user and the users in handles. A pair of users only ever has one
+// one-to-one conversation, starting it again returns the existing one with 200. Users blocked either way can't be added
+func (cfg *apiConfig) startConversation(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Handles []string `json:"handles"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil || len(params.Handles) == 0 || len(params.Handles) >= maxConversationMembers {
+		rtn := &returnErrors{Error: fmt.Sprintf("handles must list 1 to %d users", maxConversationMembers-1)}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal conversation handles error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	//the caller and repeated handles are dropped, the caller is always a member
+	otherIDs := []uuid.UUID{}
+	seen := map[uuid.UUID]bool{userID: true}
+	blocked := false
+	for _, handle := range params.Handles {
+		var memberID uuid.UUID
+		memberID, err = cfg.database.GetUserIDByHandle(r.Context(), handle)
+		if err != nil {
+			break
+		}
+		if !seen[memberID] {
+			seen[memberID] = true
+			otherIDs = append(otherIDs, memberID)
+		}
+	}
+	if err == nil && len(otherIDs) > 0 {
+		blocked, err = cfg.database.IsBlockedByAny(r.Context(), database.IsBlockedByAnyParams{UserID: userID, OtherIds: otherIDs})
+	}
+	if err != nil || len(otherIDs) == 0 || blocked {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for users"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "User not found"
+		} else if err == nil && blocked {
+			status = 403
+			rtn.Error = "You can't message users you blocked or who blocked you"
+		} else if err == nil {
+			status = 400
+			rtn.Error = "You can't start a conversation with yourself"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal conversation lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	row, created, err := cfg.createConversation(r.Context(), userID, otherIDs)
+	var conversations []Conversation
+	if err == nil {
+		conversations, err = cfg.conversationResponses(r.Context(), []database.GetConversationsRow{{
+			ID:            row.ID,
+			DirectKey:     row.DirectKey,
+			CreatedAt:     row.CreatedAt,
+			LastMessageAt: row.LastMessageAt,
+		}})
+	}
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to start conversation"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal start conversation error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error starting conversation: %s\n", err)
+		return
+	}
+	rtn := conversations[0]
+	rtn.UnreadCount = nil
+
+	status := 201
+	if !created {
+		status = http.StatusOK
+	}
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling conversation: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(dat)
+}
+
+// Creates the conversation with its members, created is false when a one-to-one conversation between the two
+// users already existed and that one is returned instead
+func (cfg *apiConfig) createConversation(ctx context.Context, creatorID uuid.UUID, otherIDs []uuid.UUID) (database.Conversation, bool, error) {
+	var directKey sql.NullString
+	if len(otherIDs) == 1 {
+		directKey = sql.NullString{String: directConversationKey(creatorID, otherIDs[0]), Valid: true}
+		existing, err := cfg.database.GetDirectConversation(ctx, directKey)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return existing, false, err
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Conversation{}, false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	conversation, err := qtx.CreateConversation(ctx, directKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		//the other user started the same conversation at the same time, theirs is the one both of them use
+		existing, err := cfg.database.GetDirectConversation(ctx, directKey)
+		return existing, false, err
+	}
+	if err != nil {
+		return database.Conversation{}, false, err
+	}
+	for _, memberID := range append([]uuid.UUID{creatorID}, otherIDs...) {
+		err = qtx.AddConversationMember(ctx, database.AddConversationMemberParams{ConversationID: conversation.ID, UserID: memberID})
+		if err != nil {
+			return database.Conversation{}, false, err
+		}
+	}
+	return conversation, true, tx.Commit()
+}
+
+// the same for both users whichever of them starts the conversation
+func directConversationKey(a, b uuid.UUID) string {
+	if a.String() > b.String() {
+		a, b = b, a
+	}
+	return a.String() + ":" + b.String()
+}
+
+// Lists the authenticated user's conversations, the one with the most recent message first
+func (cfg *apiConfig) getConversations(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Conversations []Conversation `json:"conversations"`
+		NextCursor    string         `json:"next_cursor,omitempty"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	limit, err := pageLimit(r, 20, 100)
+	if err != nil {
+		rtn := &returnErrors{Error: "limit must be a positive number"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal conversations limit error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	listParams := database.GetConversationsParams{UserID: userID, PageLimit: limit}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			rtn := &returnErrors{Error: "Invalid cursor"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal conversations cursor error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+		listParams.CursorCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		listParams.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	rows, err := cfg.database.GetConversations(r.Context(), listParams)
+	if err != nil {
+		fmt.Printf("Error querying conversations: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	conversations, err := cfg.conversationResponses(r.Context(), rows)
+	if err != nil {
+		fmt.Printf("Error querying conversation members: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	rtn := response{Conversations: conversations}
+	//pages follow activity, so the cursor is the last conversation's latest message time
+	if len(rows) == int(limit) {
+		last := rows[len(rows)-1]
+		rtn.NextCursor = pagination.EncodeCursor(last.LastMessageAt, last.ID)
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling conversations: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Builds the API Conversation for each row, members are looked up in one batch
+func (cfg *apiConfig) conversationResponses(ctx context.Context, rows []database.GetConversationsRow) ([]Conversation, error) {
+	ids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	memberRows, err := cfg.database.GetConversationMembers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[uuid.UUID][]ConversationMember, len(rows))
+	for _, m := range memberRows {
+		members[m.ConversationID] = append(members[m.ConversationID], ConversationMember{
+			ID:          m.ID,
+			Handle:      m.Handle.String,
+			DisplayName: m.DisplayName,
+		})
+	}
+
+	conversations := make([]Conversation, 0, len(rows))
+	for _, row := range rows {
+		unread := row.UnreadCount
+		conversations = append(conversations, Conversation{
+			ID:            row.ID,
+			Members:       members[row.ID],
+			CreatedAt:     row.CreatedAt,
+			LastMessageAt: row.LastMessageAt,
+			UnreadCount:   &unread,
+		})
+	}
+	return conversations, nil
+}
+
+// Sends a message to a conversation the authenticated user is a member of. It's delivered in real time on every
+// member's messages topic, the sender's included so their other sessions see it too
+func (cfg *apiConfig) sendMessage(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	conversation, err := cfg.memberConversation(r, userID)
+	var memberIDs []uuid.UUID
+	if err == nil {
+		memberIDs, err = cfg.database.GetConversationMemberIDs(r.Context(), conversation.ID)
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for conversation"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "Conversation not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal send message lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil || strings.TrimSpace(params.Body) == "" || len([]rune(params.Body)) > maxMessageLength {
+		rtn := &returnErrors{Error: fmt.Sprintf("body must be 1 to %d characters", maxMessageLength)}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal message body error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	//a block between the sender and any member closes the conversation to the sender
+	blocked, err := cfg.database.IsBlockedByAny(r.Context(), database.IsBlockedByAnyParams{UserID: userID, OtherIds: memberIDs})
+	var message Message
+	if err == nil && !blocked {
+		message, err = cfg.createMessage(r.Context(), conversation.ID, userID, params.Body, memberIDs)
+	}
+	if err != nil || blocked {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to send message"}
+		if err == nil {
+			status = 403
+			rtn.Error = "You can't message users you blocked or who blocked you"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal send message error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		if status == 503 {
+			fmt.Printf("Error sending message: %s\n", err)
+		}
+		return
+	}
+
+	dat, err := json.Marshal(message)
+	if err != nil {
+		fmt.Printf("Error marshalling message: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(dat)
+}
+
+// Stores the message, moves the conversation to the top of everyone's list and marks it read for the sender.
+// The message event is written in the same transaction so it's only delivered if the message is
+func (cfg *apiConfig) createMessage(ctx context.Context, conversationID, senderID uuid.UUID, body string, memberIDs []uuid.UUID) (Message, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Message{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	row, err := qtx.CreateMessage(ctx, database.CreateMessageParams{ConversationID: conversationID, SenderID: senderID, Body: body})
+	if err != nil {
+		return Message{}, err
+	}
+	err = qtx.TouchConversation(ctx, database.TouchConversationParams{ID: conversationID, LastMessageAt: row.CreatedAt})
+	if err != nil {
+		return Message{}, err
+	}
+	_, err = qtx.MarkConversationRead(ctx, database.MarkConversationReadParams{ConversationID: conversationID, UserID: senderID})
+	if err != nil {
+		return Message{}, err
+	}
+
+	message := messageResponse(row)
+	topics := make([]string, 0, len(memberIDs))
+	for _, memberID := range memberIDs {
+		topics = append(topics, messagesTopic(memberID))
+	}
+	err = publishEvent(ctx, qtx, "message", topics, message)
+	if err != nil {
+		return Message{}, err
+	}
+	return message, tx.Commit()
+}
+
+// Lists a conversation's messages newest first, messages from users blocked either way are left out
+func (cfg *apiConfig) getMessages(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Messages   []Message `json:"messages"`
+		NextCursor string    `json:"next_cursor,omitempty"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	conversation, err := cfg.memberConversation(r, userID)
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for conversation"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "Conversation not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal messages lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	limit, err := pageLimit(r, 50, 200)
+	if err != nil {
+		rtn := &returnErrors{Error: "limit must be a positive number"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal messages limit error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	messageParams := database.GetMessagesParams{ConversationID: conversation.ID, ViewerID: userID, PageLimit: limit}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			rtn := &returnErrors{Error: "Invalid cursor"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal messages cursor error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+		messageParams.CursorCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		messageParams.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	rows, err := cfg.database.GetMessages(r.Context(), messageParams)
+	if err != nil {
+		fmt.Printf("Error querying messages: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	rtn := response{Messages: make([]Message, 0, len(rows))}
+	for _, row := range rows {
+		rtn.Messages = append(rtn.Messages, messageResponse(row))
+	}
+	if len(rows) == int(limit) {
+		last := rows[len(rows)-1]
+		rtn.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling messages: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Marks every message in the conversation read for the authenticated user
+func (cfg *apiConfig) markConversationRead(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	//only members have a row to update, anyone else is told the conversation doesn't exist
+	marked := int64(0)
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err == nil {
+		marked, err = cfg.database.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+			ConversationID: conversationID,
+			UserID:         userID,
+		})
+	}
+	if err != nil || marked == 0 {
+		status := 404
+		rtn := &returnErrors{Error: "Conversation not found"}
+		if err != nil && conversationID != uuid.Nil {
+			status = 503
+			rtn.Error = "Failed to mark conversation read"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal mark conversation read error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// looks up the conversation in the path, conversations the user isn't in and malformed IDs come back as sql.ErrNoRows
+func (cfg *apiConfig) memberConversation(r *http.Request, userID uuid.UUID) (database.Conversation, error) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		return database.Conversation{}, sql.ErrNoRows
+	}
+	return cfg.database.GetConversation(r.Context(), database.GetConversationParams{ID: conversationID, UserID: userID})
+}
+
+func messageResponse(row database.Message) Message {
+	return Message{
+		ID:             row.ID,
+		ConversationID: row.ConversationID,
+		SenderID:       row.SenderID,
+		Body:           row.Body,
+		CreatedAt:      row.CreatedAt,
+	}
+}
+
+func messagesTopic(userID uuid.UUID) string {
+	return "messages:" + userID.String()
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/pagination"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// notification types, the rows themselves are written by triggers in the database
+var notificationTypes = []string{"reply", "like", "follow", "mention"}
+
+// moderation notifications, they aren't in notificationTypes so users can't mute them
+const reportResolvedNotification = "report_resolved"
+const warningNotification = "warning"
+
+// moderation notifications have no actor, they carry the report they're about instead
+type Notification struct {
+	ID           uuid.UUID  `json:"id"`
+	Type         string     `json:"type"`
+	ActorID      *uuid.UUID `json:"actor_id,omitempty"`
+	ActorHandle  string     `json:"actor_handle,omitempty"`
+	ChirpID      *uuid.UUID `json:"chirp_id,omitempty"`
+	ReportID     *uuid.UUID `json:"report_id,omitempty"`
+	ReportReason string     `json:"report_reason,omitempty"`
+	ReportStatus string     `json:"report_status,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Read         bool       `json:"read"`
+}
+
+// Lists the authenticated user's notifications newest first, ?unread=true leaves out ones already read
+func (cfg *apiConfig) getNotifications(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Notifications []Notification `json:"notifications"`
+		UnreadCount   int64          `json:"unread_count"`
+		NextCursor    string         `json:"next_cursor,omitempty"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	limit, err := pageLimit(r, 20, 100)
+	if err != nil {
+		rtn := &returnErrors{Error: "limit must be a positive number"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal notifications limit error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	listParams := database.GetNotificationsParams{
+		UserID:     userID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		PageLimit:  limit,
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			rtn := &returnErrors{Error: "Invalid cursor"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal notifications cursor error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+		listParams.CursorCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		listParams.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	rows, err := cfg.database.GetNotifications(r.Context(), listParams)
+	if err != nil {
+		fmt.Printf("Error querying notifications: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	unread, err := cfg.database.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		fmt.Printf("Error counting unread notifications: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+
+	rtn := response{Notifications: make([]Notification, 0, len(rows)), UnreadCount: unread}
+	for _, row := range rows {
+		n := Notification{
+			ID:           row.ID,
+			Type:         row.Type,
+			ActorHandle:  row.ActorHandle.String,
+			ReportReason: row.ReportReason.String,
+			ReportStatus: row.ReportStatus.String,
+			CreatedAt:    row.CreatedAt,
+			Read:         row.ReadAt.Valid,
+		}
+		if row.ActorID.Valid {
+			actorID := row.ActorID.UUID
+			n.ActorID = &actorID
+		}
+		if row.ChirpID.Valid {
+			chirpID := row.ChirpID.UUID
+			n.ChirpID = &chirpID
+		}
+		if row.ReportID.Valid {
+			reportID := row.ReportID.UUID
+			n.ReportID = &reportID
+		}
+		rtn.Notifications = append(rtn.Notifications, n)
+	}
+	if len(rows) == int(limit) {
+		last := rows[len(rows)-1]
+		rtn.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling notifications: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Marks the notifications listed in ids as read, a request without ids marks all of them
+func (cfg *apiConfig) markNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		IDs []uuid.UUID `json:"ids"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil && !errors.Is(err, io.EOF) {
+		rtn := &returnErrors{Error: "Unable to decode json POST request."}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal mark read decode error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	if len(params.IDs) == 0 {
+		_, err = cfg.database.MarkAllNotificationsRead(r.Context(), userID)
+	} else {
+		_, err = cfg.database.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{UserID: userID, Ids: params.IDs})
+	}
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to mark notifications read"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal mark read error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error marking notifications read: %s\n", err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// Returns which notification types the authenticated user receives
+func (cfg *apiConfig) getNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	prefs, err := cfg.notificationPreferences(r.Context(), userID)
+	if err != nil {
+		fmt.Printf("Error querying notification preferences: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	dat, err := json.Marshal(prefs)
+	if err != nil {
+		fmt.Printf("Error marshalling notification preferences: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Turns notification types on or off, takes a map of type to enabled, types left out are unchanged
+func (cfg *apiConfig) updateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := map[string]bool{}
+	err = decoder.Decode(&params)
+	if err == nil {
+		for notificationType := range params {
+			if !slices.Contains(notificationTypes, notificationType) {
+				err = fmt.Errorf("unknown notification type: %s", notificationType)
+				break
+			}
+		}
+	}
+	if err != nil {
+		rtn := &returnErrors{Error: "Body must map notification types (reply, like, follow, mention) to true or false"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal notification preferences decode error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	for notificationType, enabled := range params {
+		if enabled {
+			err = cfg.database.UnmuteNotificationType(r.Context(), database.UnmuteNotificationTypeParams{UserID: userID, Type: notificationType})
+		} else {
+			err = cfg.database.MuteNotificationType(r.Context(), database.MuteNotificationTypeParams{UserID: userID, Type: notificationType})
+		}
+		if err != nil {
+			fmt.Printf("Error updating notification preferences: %s\n", err)
+			w.WriteHeader(503)
+			return
+		}
+	}
+
+	prefs, err := cfg.notificationPreferences(r.Context(), userID)
+	if err != nil {
+		fmt.Printf("Error querying notification preferences: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	dat, err := json.Marshal(prefs)
+	if err != nil {
+		fmt.Printf("Error marshalling notification preferences: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Publishes freshly written notifications to their recipients' notification topics,
+// q is the transaction that wrote them so the events commit along with the notifications
+func (cfg *apiConfig) publishNotifications(ctx context.Context, q *database.Queries, rows []database.GetNewNotificationsRow) error {
+	for _, row := range rows {
+		n := Notification{
+			ID:          row.ID,
+			Type:        row.Type,
+			ActorHandle: row.ActorHandle.String,
+			CreatedAt:   row.CreatedAt,
+		}
+		if row.ActorID.Valid {
+			actorID := row.ActorID.UUID
+			n.ActorID = &actorID
+		}
+		if row.ChirpID.Valid {
+			chirpID := row.ChirpID.UUID
+			n.ChirpID = &chirpID
+		}
+		err := publishEvent(ctx, q, "notification", []string{notificationsTopic(row.UserID)}, n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func notificationsTopic(userID uuid.UUID) string {
+	return "notifications:" + userID.String()
+}
+
+// every type is enabled unless the user muted it
+func (cfg *apiConfig) notificationPreferences(ctx context.Context, userID uuid.UUID) (map[string]bool, error) {
+	muted, err := cfg.database.GetMutedNotificationTypes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	prefs := make(map[string]bool, len(notificationTypes))
+	for _, notificationType := range notificationTypes {
+		prefs[notificationType] = true
+	}
+	for _, notificationType := range muted {
+		prefs[notificationType] = false
+	}
+	return prefs, nil
+}
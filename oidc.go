@@ -0,0 +1,373 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/statusquonjc46/chirpy-http/internal/auth"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/oidc"
+	"github.com/statusquonjc46/chirpy-http/internal/webhooks"
+	"net/http"
+	"time"
+)
+
+var errEmailInUse = errors.New("email belongs to an account with a password")
+
+// cookie tying a login's state to the browser that started it, it lives as long as the stored state
+const oidcStateCookie = "chirpy_oidc_state"
+const oidcStateTTL = 10 * time.Minute
+
+// Starts the OIDC authorization code flow, stores state/nonce/PKCE verifier and sets the state cookie, then redirects to the identity provider.
+// ?restore=true restores an account pending deletion once the login completes, like POST /api/users/restore
+func (cfg *apiConfig) oidcLogin(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	if cfg.oidc == nil {
+		rtn := &returnErrors{Error: "External login is not configured"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal oidc not configured error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(404)
+		w.Write(dat)
+		return
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		fmt.Printf("Failed to generate oidc state: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		fmt.Printf("Failed to generate oidc nonce: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		fmt.Printf("Failed to generate PKCE verifier: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	//clean up abandoned logins before storing this one
+	err = cfg.database.DeleteExpiredOIDCLoginStates(r.Context())
+	if err != nil {
+		fmt.Printf("Failed to delete expired oidc login states: %s\n", err)
+	}
+
+	stateParams := database.CreateOIDCLoginStateParams{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().UTC().Add(oidcStateTTL),
+		Restore:      r.URL.Query().Get("restore") == "true",
+	}
+	err = cfg.database.CreateOIDCLoginState(r.Context(), stateParams)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to start external login"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal oidc state storage error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error storing oidc login state: %s\n", err)
+		return
+	}
+
+	//Lax still sends the cookie on the provider's top level redirect back to the callback
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/auth/oidc/callback",
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, cfg.oidc.AuthCodeURL(state, nonce, verifier), http.StatusFound)
+}
+
+// Handles the identity provider redirect: checks state against the login cookie, exchanges the code, validates the ID token, links or creates the user and issues a Chirpy token
+func (cfg *apiConfig) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	if cfg.oidc == nil {
+		rtn := &returnErrors{Error: "External login is not configured"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal oidc not configured error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(404)
+		w.Write(dat)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("error") != "" || query.Get("code") == "" || query.Get("state") == "" {
+		rtn := &returnErrors{Error: "External login failed"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal oidc callback error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		fmt.Printf("OIDC callback error: %s %s\n", query.Get("error"), query.Get("error_description"))
+		return
+	}
+
+	//the state has to match the cookie set when this browser started the login, otherwise anyone could send a victim
+	//a callback URL carrying their own code and state and log the victim into the attacker's account
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		rtn := &returnErrors{Error: "Login state is invalid or expired"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal oidc state cookie error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/api/auth/oidc/callback", MaxAge: -1})
+
+	//state is single use, consuming it stops replayed callbacks
+	loginState, err := cfg.database.ConsumeOIDCLoginState(r.Context(), query.Get("state"))
+	if err != nil {
+		rtn := &returnErrors{Error: "Login state is invalid or expired"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal oidc state error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	rawIDToken, err := cfg.oidc.Exchange(r.Context(), query.Get("code"), loginState.CodeVerifier)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to exchange authorization code"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal oidc exchange error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		fmt.Printf("Error exchanging oidc code: %s\n", err)
+		return
+	}
+
+	claims, err := cfg.oidc.VerifyIDToken(r.Context(), rawIDToken, loginState.Nonce)
+	if err != nil {
+		rtn := &returnErrors{Error: "Invalid ID token"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal id token error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		fmt.Printf("Error validating id token: %s\n", err)
+		return
+	}
+
+	//find the linked chirpy user, or link one on first login
+	identityParams := database.GetUserByIdentityParams{Issuer: claims.Issuer, Subject: claims.Subject}
+	user, err := cfg.database.GetUserByIdentity(r.Context(), identityParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		user, err = cfg.linkOIDCUser(r, claims)
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to link external account"}
+		if errors.Is(err, errEmailInUse) {
+			status = 409
+			rtn.Error = "An account with this email already exists, log in with your password instead"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal oidc user lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		fmt.Printf("Error linking oidc user: %s\n", err)
+		return
+	}
+
+	//external only accounts have no password for POST /api/users/restore, so they're restored by logging in with ?restore=true
+	if user.DeletedAt.Valid && loginState.Restore {
+		user, err = cfg.database.RestoreUser(r.Context(), user.ID)
+		if err != nil {
+			status := 503
+			rtn := &returnErrors{Error: "Failed to restore account"}
+			if errors.Is(err, sql.ErrNoRows) {
+				status = 409
+				rtn.Error = "Account is not pending deletion"
+			}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal oidc restore error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write(dat)
+			return
+		}
+	}
+	if user.DeletedAt.Valid {
+		rtn := &returnErrors{Error: "Account is scheduled for deletion, log in with ?restore=true to restore it"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal deleted account error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(403)
+		w.Write(dat)
+		return
+	}
+
+	suspended, err := cfg.database.IsSuspended(r.Context(), user.ID)
+	if err != nil || suspended {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for suspension"}
+		if err == nil {
+			status = 403
+			rtn.Error = "Account is suspended"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal suspended account error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, time.Hour)
+	if err != nil {
+		fmt.Printf("Error making JWT for oidc user: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	refreshToken, err := cfg.createSession(r, user.ID)
+	if err != nil {
+		fmt.Printf("Error creating session for oidc user: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	authedUser := &User{
+		ID:           user.ID,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+		Email:        user.Email.String,
+		IsChirpyRed:  user.IsChirpyRed,
+		Token:        token,
+		RefreshToken: refreshToken,
+	}
+
+	dat, err := json.Marshal(authedUser)
+	if err != nil {
+		fmt.Printf("Error marshalling json for oidc user: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Links the external subject of a first time external login to a chirpy user in one transaction. A verified email that
+// already belongs to another external only account links to that account, otherwise a new user is created. Accounts
+// with a password are never linked, their email was never verified
+func (cfg *apiConfig) linkOIDCUser(r *http.Request, claims *oidc.Claims) (database.User, error) {
+	//only trust the email if the provider verified it
+	email := sql.NullString{String: claims.Email, Valid: claims.Email != "" && claims.EmailVerified}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	var user database.User
+	created := false
+	if email.Valid {
+		user, err = qtx.UserandHashLookup(r.Context(), email.String)
+		//signup emails aren't verified, so an account with a password may not belong to this person at all
+		if err == nil && user.HashedPassword != "unset" {
+			return database.User{}, errEmailInUse
+		}
+	}
+	if !email.Valid || errors.Is(err, sql.ErrNoRows) {
+		//external users have no password, "unset" never matches a bcrypt hash so password login stays disabled
+		user, err = qtx.CreateUser(r.Context(), database.CreateUserParams{Email: email, HashedPassword: "unset"})
+		created = true
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	identityParams := database.CreateUserIdentityParams{
+		UserID:  user.ID,
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+	}
+	_, err = qtx.CreateUserIdentity(r.Context(), identityParams)
+	if err != nil {
+		return database.User{}, err
+	}
+	if created {
+		err = enqueueWebhook(r.Context(), qtx, webhooks.UserCreated, user.ID, webhookUser{ID: user.ID, CreatedAt: user.CreatedAt, Email: user.Email.String})
+		if err != nil {
+			return database.User{}, err
+		}
+	}
+
+	return user, tx.Commit()
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/auth"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/webhooks"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// deliveries that fail this many times in a row are moved to the dead letters
+const webhookMaxAttempts = 10
+const webhookBatchSize = 20
+const webhookTimeout = 10 * time.Second
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDeadLetter struct {
+	ID             uuid.UUID `json:"id"`
+	EndpointID     uuid.UUID `json:"endpoint_id"`
+	URL            string    `json:"url"`
+	EventID        uuid.UUID `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	Attempts       int32     `json:"attempts"`
+	LastStatusCode *int32    `json:"last_status_code,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	FailedAt       time.Time `json:"failed_at"`
+}
+
+// body of every delivery, id stays the same across retries and redeliveries so receivers can drop duplicates
+type webhookPayload struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// user.created data, only admin endpoints receive it
+type webhookUser struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Email     string    `json:"email,omitempty"`
+}
+
+// chirp.deleted data
+type webhookDeletedChirp struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// Registers an endpoint for outbound webhooks. Requests with the admin API key ("Authorization: ApiKey <key>") create
+// endpoints that get every event, JWT users get chirp events for their own chirps. The signing secret is only returned here
+func (cfg *apiConfig) createWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	owner, err := cfg.webhookOwner(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err == nil {
+		err = webhooks.ValidateURL(params.URL)
+	}
+	if err == nil {
+		err = validateWebhookEvents(params.Events, owner.Valid)
+	}
+	if err != nil {
+		rtn := &returnErrors{Error: err.Error()}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal webhook endpoint params error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		fmt.Printf("Error generating webhook secret: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	endpoint, err := cfg.database.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID:     owner,
+		Url:        params.URL,
+		Secret:     secret,
+		EventTypes: params.Events,
+	})
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to create webhook endpoint"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal webhook endpoint DB error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error creating webhook endpoint: %s\n", err)
+		return
+	}
+
+	rtn := webhookEndpointResponse(endpoint)
+	rtn.Secret = endpoint.Secret
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling webhook endpoint: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(dat)
+}
+
+// Lists the caller's webhook endpoints, admin key callers see the admin endpoints
+func (cfg *apiConfig) getWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	owner, err := cfg.webhookOwner(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	rows, err := cfg.database.GetWebhookEndpoints(r.Context(), owner)
+	if err != nil {
+		fmt.Printf("Error querying webhook endpoints: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	rtn := make([]WebhookEndpoint, 0, len(rows))
+	for _, row := range rows {
+		rtn = append(rtn, webhookEndpointResponse(row))
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling webhook endpoints: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Removes one of the caller's webhook endpoints along with its queued deliveries
+func (cfg *apiConfig) deleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	owner, err := cfg.webhookOwner(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	//an unparseable ID can't match an endpoint, it's reported the same as a missing one
+	var deleted int64
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err == nil {
+		deleted, err = cfg.database.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+			ID:     endpointID,
+			UserID: owner,
+		})
+		if err != nil {
+			fmt.Printf("Error deleting webhook endpoint: %s\n", err)
+			w.WriteHeader(503)
+			return
+		}
+	}
+	if deleted == 0 {
+		rtn := &returnErrors{Error: "Webhook endpoint not found"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal webhook endpoint not found error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(404)
+		w.Write(dat)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// Lists deliveries to the caller's endpoints that ran out of retries, most recently failed first
+func (cfg *apiConfig) getWebhookDeadLetters(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	owner, err := cfg.webhookOwner(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	limit, err := pageLimit(r, 50, 200)
+	if err != nil {
+		rtn := &returnErrors{Error: "limit must be a positive number"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal dead letters limit error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	rows, err := cfg.database.GetWebhookDeadLetters(r.Context(), database.GetWebhookDeadLettersParams{
+		UserID: owner,
+		Limit:  limit,
+	})
+	if err != nil {
+		fmt.Printf("Error querying webhook dead letters: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	rtn := make([]WebhookDeadLetter, 0, len(rows))
+	for _, row := range rows {
+		dl := WebhookDeadLetter{
+			ID:         row.ID,
+			EndpointID: row.EndpointID,
+			URL:        row.Url,
+			EventID:    row.EventID,
+			EventType:  row.EventType,
+			Attempts:   row.Attempts,
+			LastError:  row.LastError.String,
+			CreatedAt:  row.CreatedAt,
+			FailedAt:   row.UpdatedAt,
+		}
+		if row.LastStatusCode.Valid {
+			code := row.LastStatusCode.Int32
+			dl.LastStatusCode = &code
+		}
+		rtn = append(rtn, dl)
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling webhook dead letters: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Queues a delivery again with a fresh set of retries, works for dead, pending and already delivered deliveries
+func (cfg *apiConfig) redeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	owner, err := cfg.webhookOwner(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	var queued int64
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err == nil {
+		queued, err = cfg.database.RedeliverWebhook(r.Context(), database.RedeliverWebhookParams{
+			ID:     deliveryID,
+			UserID: owner,
+		})
+		if err != nil {
+			fmt.Printf("Error queueing webhook redelivery: %s\n", err)
+			w.WriteHeader(503)
+			return
+		}
+	}
+	if queued == 0 {
+		rtn := &returnErrors{Error: "Webhook delivery not found"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal webhook delivery not found error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(404)
+		w.Write(dat)
+		return
+	}
+	w.WriteHeader(202)
+}
+
+// Works out who owns the webhooks a request manages, an invalid UUID means the admin endpoints.
+// A request that sends an API key has to send the right one, it doesn't fall back to the JWT
+func (cfg *apiConfig) webhookOwner(r *http.Request) (uuid.NullUUID, error) {
+	if apiKey, err := auth.GetAPIKey(r.Header); err == nil {
+		if !auth.SecretsMatch(apiKey, cfg.adminAPIKey) {
+			return uuid.NullUUID{}, errors.New("invalid admin API key")
+		}
+		return uuid.NullUUID{}, nil
+	}
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}, nil
+}
+
+func validateWebhookEvents(events []string, userEndpoint bool) error {
+	if len(events) == 0 {
+		return errors.New("events must list at least one event type")
+	}
+	for _, ev := range events {
+		if !slices.Contains(webhooks.EventTypes, ev) {
+			return fmt.Errorf("unknown event type %q", ev)
+		}
+		if userEndpoint && ev == webhooks.UserCreated {
+			return errors.New("user.created is only available to admin endpoints")
+		}
+	}
+	return nil
+}
+
+func webhookEndpointResponse(row database.WebhookEndpoint) WebhookEndpoint {
+	return WebhookEndpoint{
+		ID:        row.ID,
+		URL:       row.Url,
+		Events:    row.EventTypes,
+		CreatedAt: row.CreatedAt,
+	}
+}
+
+// Queues the event for every endpoint subscribed to it, subjectUserID is the user the event is about and decides
+// which user endpoints get it. Pass a transaction's queries to only queue the event if the change commits
+func enqueueWebhook(ctx context.Context, q *database.Queries, eventType string, subjectUserID uuid.UUID, data any) error {
+	eventID := uuid.New()
+	payload, err := json.Marshal(webhookPayload{
+		ID:        eventID,
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = q.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventID:       eventID,
+		EventType:     eventType,
+		Payload:       payload,
+		SubjectUserID: uuid.NullUUID{UUID: subjectUserID, Valid: true},
+	})
+	return err
+}
+
+// Sends due webhook deliveries until ctx ends. Deliveries are claimed with SKIP LOCKED so any number of instances can run this
+func (cfg *apiConfig) deliverWebhooksJob(ctx context.Context, interval time.Duration) {
+	client := webhooks.NewClient(webhookTimeout)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		//keep going while full batches come back so a backlog drains without waiting on the ticker
+		for {
+			deliveries, err := cfg.database.ClaimWebhookDeliveries(ctx, webhookBatchSize)
+			if err != nil {
+				fmt.Printf("Failed to claim webhook deliveries: %s\n", err)
+				break
+			}
+			for _, delivery := range deliveries {
+				cfg.sendWebhook(ctx, client, delivery)
+			}
+			if len(deliveries) < webhookBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Posts one delivery and records the result, failures are retried with exponential backoff until webhookMaxAttempts
+func (cfg *apiConfig) sendWebhook(ctx context.Context, client *http.Client, delivery database.ClaimWebhookDeliveriesRow) {
+	now := time.Now().UTC()
+	statusCode, err := postWebhook(ctx, client, delivery, now)
+	code := sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0}
+	if err == nil {
+		err = cfg.database.MarkWebhookDelivered(ctx, database.MarkWebhookDeliveredParams{
+			ID:             delivery.ID,
+			LastStatusCode: code,
+		})
+		if err != nil {
+			fmt.Printf("Failed to mark webhook %s delivered: %s\n", delivery.ID, err)
+		}
+		return
+	}
+
+	attempts := int(delivery.Attempts) + 1
+	failed := database.MarkWebhookFailedParams{
+		ID:             delivery.ID,
+		Status:         "pending",
+		LastStatusCode: code,
+		LastError:      sql.NullString{String: err.Error(), Valid: true},
+		NextAttemptAt:  sql.NullTime{Time: now.Add(webhooks.Backoff(attempts)), Valid: true},
+	}
+	if attempts >= webhookMaxAttempts {
+		failed.Status = "dead"
+		failed.NextAttemptAt = sql.NullTime{}
+	}
+	err = cfg.database.MarkWebhookFailed(ctx, failed)
+	if err != nil {
+		fmt.Printf("Failed to record webhook %s failure: %s\n", delivery.ID, err)
+	}
+}
+
+// Signs and posts the payload, a non 2xx response is an error. The status code is 0 when no response came back
+func postWebhook(ctx context.Context, client *http.Client, delivery database.ClaimWebhookDeliveriesRow, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set("X-Chirpy-Event", delivery.EventType)
+	req.Header.Set("X-Chirpy-Delivery", delivery.ID.String())
+	req.Header.Set("X-Chirpy-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set("X-Chirpy-Signature", webhooks.Sign(delivery.Secret, now, delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	//the body isn't kept, last_error is shown to the endpoint's owner and mustn't become a way to read responses
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
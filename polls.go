@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const minPollOptions = 2
+const maxPollOptions = 4
+const maxPollOptionLength = 25
+
+// how long a poll can run, measured from when its chirp is posted
+const minPollDuration = 5 * time.Minute
+const maxPollDuration = 7 * 24 * time.Hour
+
+var errInvalidPoll = errors.New("invalid poll")
+
+// Votes and TotalVotes are left out until the viewer has voted or the poll has closed, so early tallies can't sway votes
+type Poll struct {
+	ClosesAt      time.Time    `json:"closes_at"`
+	Closed        bool         `json:"closed"`
+	TotalVotes    *int32       `json:"total_votes,omitempty"`
+	VotedOptionID *uuid.UUID   `json:"voted_option_id,omitempty"`
+	Options       []PollOption `json:"options"`
+}
+
+type PollOption struct {
+	ID    uuid.UUID `json:"id"`
+	Label string    `json:"label"`
+	Votes *int32    `json:"votes,omitempty"`
+}
+
+// poll field of POST /api/chirps
+type pollParameters struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+}
+
+// a validated poll waiting to be inserted with its chirp
+type newPoll struct {
+	Options  []string
+	ClosesAt time.Time
+}
+
+// Checks a poll sent with a new chirp, nil means the chirp has no poll
+func validatePoll(params *pollParameters) (*newPoll, error) {
+	if params == nil {
+		return nil, nil
+	}
+	if len(params.Options) < minPollOptions || len(params.Options) > maxPollOptions {
+		return nil, fmt.Errorf("%w: a poll needs %d to %d options", errInvalidPoll, minPollOptions, maxPollOptions)
+	}
+	options := make([]string, 0, len(params.Options))
+	seen := make(map[string]bool, len(params.Options))
+	for _, option := range params.Options {
+		label := strings.TrimSpace(option)
+		if label == "" || len([]rune(label)) > maxPollOptionLength {
+			return nil, fmt.Errorf("%w: options must be 1 to %d characters", errInvalidPoll, maxPollOptionLength)
+		}
+		if seen[strings.ToLower(label)] {
+			return nil, fmt.Errorf("%w: options must be distinct", errInvalidPoll)
+		}
+		seen[strings.ToLower(label)] = true
+		options = append(options, label)
+	}
+	untilClose := time.Until(params.ClosesAt)
+	if untilClose < minPollDuration || untilClose > maxPollDuration {
+		return nil, fmt.Errorf("%w: closes_at must be between %s and %s from now", errInvalidPoll, minPollDuration, maxPollDuration)
+	}
+	//the column has no time zone, store UTC so any offset the client sent is honoured
+	return &newPoll{Options: options, ClosesAt: params.ClosesAt.UTC()}, nil
+}
+
+// Inserts a new chirp's poll inside the chirp's transaction, options keep the order they were given in
+func insertPoll(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, poll *newPoll) error {
+	err := qtx.CreatePoll(ctx, database.CreatePollParams{ChirpID: chirpID, ClosesAt: poll.ClosesAt})
+	if err != nil {
+		return err
+	}
+	for i, label := range poll.Options {
+		err = qtx.AddPollOption(ctx, database.AddPollOptionParams{ChirpID: chirpID, Position: int32(i), Label: label})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Records the authenticated user's vote in a chirp's poll and returns the poll with its results.
+// Each user votes once and votes aren't accepted once the poll has closed
+func (cfg *apiConfig) votePoll(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		OptionID string `json:"option_id"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	//chirps by deleted accounts and blocked users aren't visible, so their polls can't be voted in
+	chirp := database.Chirp{}
+	poll := database.Poll{}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		err = sql.ErrNoRows
+	} else {
+		chirp, err = cfg.visibleChirp(r.Context(), chirpID, userID)
+	}
+	if err == nil {
+		poll, err = cfg.database.GetPoll(r.Context(), chirp.ID)
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for poll"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "Poll not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal poll lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	polls, err := cfg.chirpPolls(r.Context(), []uuid.UUID{poll.ChirpID}, userID)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to query DB for poll"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal poll options error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	optionID := uuid.Nil
+	if err == nil {
+		optionID, err = uuid.Parse(params.OptionID)
+	}
+	validOption := false
+	for _, option := range polls[poll.ChirpID].Options {
+		validOption = validOption || option.ID == optionID
+	}
+	if err != nil || !validOption {
+		rtn := &returnErrors{Error: "option_id must be one of the poll's options"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal poll option error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	voted, err := cfg.database.CastPollVote(r.Context(), database.CastPollVoteParams{
+		ChirpID:  poll.ChirpID,
+		UserID:   userID,
+		OptionID: optionID,
+	})
+	if err != nil || voted == 0 {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to record vote"}
+		if err == nil && !time.Now().Before(poll.ClosesAt) {
+			status = 403
+			rtn.Error = "Poll has closed"
+		} else if err == nil {
+			status = 409
+			rtn.Error = "You have already voted in this poll"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal poll vote error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	//read the poll back so the response has the tallies including this vote
+	polls, err = cfg.chirpPolls(r.Context(), []uuid.UUID{poll.ChirpID}, userID)
+	if err != nil {
+		fmt.Printf("Error querying poll after vote: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	dat, err := json.Marshal(polls[poll.ChirpID])
+	if err != nil {
+		fmt.Printf("Error marshalling poll: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(dat)
+}
+
+// Looks up the polls on the given chirps as the viewer sees them, results are only filled in once the viewer has
+// voted or the poll has closed. viewerID is uuid.Nil for anonymous requests, they see results after closing
+func (cfg *apiConfig) chirpPolls(ctx context.Context, chirpIDs []uuid.UUID, viewerID uuid.UUID) (map[uuid.UUID]*Poll, error) {
+	rows, err := cfg.database.GetChirpPollOptions(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	votes := make(map[uuid.UUID]uuid.UUID)
+	if viewerID != uuid.Nil {
+		voteRows, err := cfg.database.GetPollVotes(ctx, database.GetPollVotesParams{UserID: viewerID, ChirpIds: chirpIDs})
+		if err != nil {
+			return nil, err
+		}
+		for _, vote := range voteRows {
+			votes[vote.ChirpID] = vote.OptionID
+		}
+	}
+
+	now := time.Now()
+	polls := make(map[uuid.UUID]*Poll)
+	for _, row := range rows {
+		poll := polls[row.ChirpID]
+		if poll == nil {
+			poll = &Poll{ClosesAt: row.ClosesAt, Closed: !now.Before(row.ClosesAt), Options: []PollOption{}}
+			if optionID, ok := votes[row.ChirpID]; ok {
+				poll.VotedOptionID = &optionID
+			}
+			if poll.Closed || poll.VotedOptionID != nil {
+				poll.TotalVotes = new(int32)
+			}
+			polls[row.ChirpID] = poll
+		}
+		option := PollOption{ID: row.ID, Label: row.Label}
+		if poll.TotalVotes != nil {
+			count := row.VoteCount
+			option.Votes = &count
+			*poll.TotalVotes += count
+		}
+		poll.Options = append(poll.Options, option)
+	}
+	return polls, nil
+}
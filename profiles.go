@@ -0,0 +1,217 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/handles"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+const maxDisplayNameLength = 50
+const maxBioLength = 160
+
+// public view of a user, never includes the email
+type Profile struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Handle         string    `json:"handle"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	ChirpCount     int64     `json:"chirp_count"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+}
+
+// Updates the authenticated user's handle, display name and bio, fields left out of the request are unchanged
+func (cfg *apiConfig) updateProfile(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unable to decode json PUT request."}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal profile decode error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	user, err := cfg.database.GetUserByID(r.Context(), userID)
+	if err != nil {
+		rtn := &returnErrors{Error: "User not found"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal profile user lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(404)
+		w.Write(dat)
+		return
+	}
+
+	profileParams := database.UpdateUserProfileParams{
+		ID:          user.ID,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+	}
+	var validationErr error
+	if params.Handle != nil {
+		validationErr = handles.Validate(*params.Handle)
+		profileParams.Handle = sql.NullString{String: *params.Handle, Valid: true}
+	}
+	if params.DisplayName != nil {
+		if utf8.RuneCountInString(*params.DisplayName) > maxDisplayNameLength {
+			validationErr = errors.New("display name is too long")
+		}
+		profileParams.DisplayName = *params.DisplayName
+	}
+	if params.Bio != nil {
+		if utf8.RuneCountInString(*params.Bio) > maxBioLength {
+			validationErr = errors.New("bio is too long")
+		}
+		profileParams.Bio = *params.Bio
+	}
+	if validationErr != nil {
+		rtn := &returnErrors{Error: validationErr.Error()}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal profile validation error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	updated, err := cfg.database.UpdateUserProfile(r.Context(), profileParams)
+	if err != nil {
+		//23505 is unique_violation, the case insensitive handle index caught a taken handle
+		var pqErr *pq.Error
+		status := 503
+		rtn := &returnErrors{Error: "Failed to update profile"}
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			status = 409
+			rtn.Error = "Handle is already taken"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal profile update error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	ret := &User{
+		ID:          updated.ID,
+		CreatedAt:   updated.CreatedAt,
+		UpdatedAt:   updated.UpdatedAt,
+		Email:       updated.Email.String,
+		IsChirpyRed: updated.IsChirpyRed,
+		Handle:      updated.Handle.String,
+		DisplayName: updated.DisplayName,
+		Bio:         updated.Bio,
+	}
+	dat, err := json.Marshal(ret)
+	if err != nil {
+		fmt.Printf("Error marshalling updated profile: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Public profile by handle with the user's chirp count
+func (cfg *apiConfig) getPublicProfile(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	row, err := cfg.database.GetPublicProfile(r.Context(), r.PathValue("handle"))
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for profile"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "User not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal profile lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	profile := Profile{
+		ID:             row.ID,
+		CreatedAt:      row.CreatedAt,
+		Handle:         row.Handle.String,
+		DisplayName:    row.DisplayName,
+		Bio:            row.Bio,
+		IsChirpyRed:    row.IsChirpyRed,
+		ChirpCount:     row.ChirpCount,
+		FollowerCount:  row.FollowerCount,
+		FollowingCount: row.FollowingCount,
+	}
+	dat, err := json.Marshal(profile)
+	if err != nil {
+		fmt.Printf("Error marshalling profile: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"net/http"
+)
+
+// Rechirps a chirp for the authenticated user, rechirping a rechirp shares the original.
+// Each user can rechirp a chirp once, repeating the request returns the existing rechirp
+func (cfg *apiConfig) rechirp(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	original, err := cfg.rechirpTarget(r, userID)
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for chirpID"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "Chirp not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal rechirp lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	rechirpParams := database.AddRechirpParams{
+		UserID:      uuid.NullUUID{UUID: userID, Valid: true},
+		RechirpOfID: uuid.NullUUID{UUID: original.ID, Valid: true},
+	}
+	status := 201
+	row, err := cfg.database.AddRechirp(r.Context(), rechirpParams)
+	if errors.Is(err, sql.ErrNoRows) {
+		//the insert hit the one rechirp per user index, hand back the one that's already there
+		status = 200
+		row, err = cfg.database.GetRechirp(r.Context(), database.GetRechirpParams(rechirpParams))
+	}
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to rechirp"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal rechirp error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error rechirping: %s\n", err)
+		return
+	}
+
+	chirps, err := cfg.chirpResponses(r.Context(), []database.Chirp{row}, userID)
+	if err != nil || len(chirps) == 0 {
+		fmt.Printf("Error building rechirp response: %v\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	dat, err := json.Marshal(chirps[0])
+	if err != nil {
+		fmt.Printf("Error marshalling rechirp: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(dat)
+}
+
+// Removes the authenticated user's rechirp of a chirp, undoing a rechirp that doesn't exist is a no-op
+func (cfg *apiConfig) undoRechirp(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	//a rechirp made before a block can still be undone, so the block isn't checked here
+	original, err := cfg.rechirpTarget(r, uuid.Nil)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(204)
+		return
+	}
+	if err == nil {
+		_, err = cfg.database.DeleteRechirp(r.Context(), database.DeleteRechirpParams{
+			UserID:      uuid.NullUUID{UUID: userID, Valid: true},
+			RechirpOfID: uuid.NullUUID{UUID: original.ID, Valid: true},
+		})
+	}
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to undo rechirp"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal undo rechirp error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error undoing rechirp: %s\n", err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// Looks up the chirp in the path, following a rechirp back to its original.
+// An unparseable ID is reported as sql.ErrNoRows, it can't match a chirp either way, as are chirps by users blocked
+// either way. userID is uuid.Nil to skip the block check
+func (cfg *apiConfig) rechirpTarget(r *http.Request, userID uuid.UUID) (database.Chirp, error) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	chirp, err := cfg.visibleChirp(r.Context(), chirpID, userID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if chirp.RechirpOfID.Valid {
+		return cfg.visibleChirp(r.Context(), chirp.RechirpOfID.UUID, userID)
+	}
+	return chirp, nil
+}
@@ -0,0 +1,902 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/auth"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/pagination"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "sexual", "impersonation", "other"}
+
+var reportStatuses = []string{"open", "dismissed", "resolved"}
+
+// what a moderator can do about a report, dismiss closes it as dismissed and the rest close it as resolved
+var reportActions = []string{"dismiss", "remove_chirp", "suspend_user", "warn_user"}
+
+const maxReportDetailsLength = 500
+
+const maxModerationNoteLength = 1000
+
+var errNotModerator = errors.New("not a moderator")
+var errAccountSuspended = errors.New("account is suspended")
+var errReportClosed = errors.New("report is already closed")
+var errNoChirpToRemove = errors.New("report has no chirp to remove")
+
+// ReporterHandle and UserHandle are only filled in for the moderation queue
+type Report struct {
+	ID             uuid.UUID  `json:"id"`
+	ReporterID     uuid.UUID  `json:"reporter_id"`
+	ReporterHandle string     `json:"reporter_handle,omitempty"`
+	UserID         uuid.UUID  `json:"user_id"`
+	UserHandle     string     `json:"user_handle,omitempty"`
+	ChirpID        *uuid.UUID `json:"chirp_id,omitempty"`
+	ChirpBody      string     `json:"chirp_body,omitempty"`
+	Reason         string     `json:"reason"`
+	Details        string     `json:"details"`
+	Status         string     `json:"status"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+}
+
+// ModeratorID is left out for actions taken with the admin API key
+type ModerationAction struct {
+	ID              uuid.UUID  `json:"id"`
+	ModeratorID     *uuid.UUID `json:"moderator_id,omitempty"`
+	ModeratorHandle string     `json:"moderator_handle,omitempty"`
+	Action          string     `json:"action"`
+	ReportID        *uuid.UUID `json:"report_id,omitempty"`
+	UserID          *uuid.UUID `json:"user_id,omitempty"`
+	ChirpID         *uuid.UUID `json:"chirp_id,omitempty"`
+	Note            string     `json:"note"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// Reports a chirp or a user to the moderators, the request names one of chirp_id or handle. A chirp report is about
+// the chirp's author and keeps a copy of the body. A reporter can only have one open report about the same thing
+func (cfg *apiConfig) createReport(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ChirpID string `json:"chirp_id"`
+		Handle  string `json:"handle"`
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unable to decode json POST request."}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal report decode error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	var chirpID uuid.UUID
+	invalid := ""
+	if (params.ChirpID == "") == (params.Handle == "") {
+		invalid = "Report either a chirp_id or a handle"
+	} else if params.ChirpID != "" {
+		chirpID, err = uuid.Parse(params.ChirpID)
+		if err != nil {
+			invalid = "chirp_id must be a UUID"
+		}
+	}
+	if invalid == "" && !slices.Contains(reportReasons, params.Reason) {
+		invalid = fmt.Sprintf("reason must be one of %s", strings.Join(reportReasons, ", "))
+	} else if invalid == "" && len([]rune(params.Details)) > maxReportDetailsLength {
+		invalid = fmt.Sprintf("details must be at most %d characters", maxReportDetailsLength)
+	}
+	if invalid != "" {
+		rtn := &returnErrors{Error: invalid}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal report validation error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	createParams := database.CreateReportParams{ReporterID: userID, Reason: params.Reason, Details: params.Details}
+	if params.ChirpID != "" {
+		var chirp database.Chirp
+		chirp, err = cfg.visibleChirp(r.Context(), chirpID, userID)
+		//a rechirp has nothing of its own to report, the report is about the chirp it reposted
+		if err == nil && chirp.RechirpOfID.Valid {
+			chirp, err = cfg.visibleChirp(r.Context(), chirp.RechirpOfID.UUID, userID)
+		}
+		createParams.UserID = chirp.UserID.UUID
+		createParams.ChirpID = uuid.NullUUID{UUID: chirp.ID, Valid: true}
+		createParams.ChirpBody = sql.NullString{String: chirp.Body, Valid: true}
+	} else {
+		createParams.UserID, err = cfg.database.GetUserIDByHandle(r.Context(), params.Handle)
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for report target"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "User not found"
+			if params.ChirpID != "" {
+				rtn.Error = "Chirp not found"
+			}
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal report target error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	if createParams.UserID == userID {
+		rtn := &returnErrors{Error: "You can't report yourself"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal self report error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	report, err := cfg.database.CreateReport(r.Context(), createParams)
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to create report"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 409
+			rtn.Error = "You already have an open report about this"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal create report error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		if status == 503 {
+			fmt.Printf("Error creating report: %s\n", err)
+		}
+		return
+	}
+
+	dat, err := json.Marshal(reportResponse(report))
+	if err != nil {
+		fmt.Printf("Error marshalling report: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(dat)
+}
+
+// The moderation queue, oldest first. Shows open reports unless ?status= asks for dismissed or resolved ones and
+// can be narrowed with ?reason=, ?handle= for the reported user and ?chirp_id=
+func (cfg *apiConfig) getReports(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Reports    []Report `json:"reports"`
+		NextCursor string   `json:"next_cursor,omitempty"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	_, err := cfg.moderatorFromRequest(r)
+	if err != nil {
+		status := 401
+		rtn := &returnErrors{Error: "Unauthorized"}
+		if errors.Is(err, errNotModerator) {
+			status = 403
+			rtn.Error = "Only moderators can see reports"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal moderator auth error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	limit, err := pageLimit(r, 20, 100)
+	if err != nil {
+		rtn := &returnErrors{Error: "limit must be a positive number"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal reports limit error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	query := r.URL.Query()
+	listParams := database.GetReportsParams{Status: "open", PageLimit: limit}
+	invalid := ""
+	if status := query.Get("status"); status != "" {
+		listParams.Status = status
+		if !slices.Contains(reportStatuses, status) {
+			invalid = fmt.Sprintf("status must be one of %s", strings.Join(reportStatuses, ", "))
+		}
+	}
+	if reason := query.Get("reason"); reason != "" {
+		listParams.Reason = sql.NullString{String: reason, Valid: true}
+		if !slices.Contains(reportReasons, reason) {
+			invalid = fmt.Sprintf("reason must be one of %s", strings.Join(reportReasons, ", "))
+		}
+	}
+	if chirpID := query.Get("chirp_id"); chirpID != "" {
+		id, err := uuid.Parse(chirpID)
+		if err != nil {
+			invalid = "chirp_id must be a UUID"
+		}
+		listParams.ChirpID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			invalid = "Invalid cursor"
+		}
+		listParams.CursorCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		listParams.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if invalid != "" {
+		rtn := &returnErrors{Error: invalid}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal reports filter error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	if handle := query.Get("handle"); handle != "" {
+		userID, err := cfg.database.GetUserIDByHandle(r.Context(), handle)
+		if err != nil {
+			status := 503
+			rtn := &returnErrors{Error: "Failed to query DB for user"}
+			if errors.Is(err, sql.ErrNoRows) {
+				status = 404
+				rtn.Error = "User not found"
+			}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal reports user lookup error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write(dat)
+			return
+		}
+		listParams.UserID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+
+	rows, err := cfg.database.GetReports(r.Context(), listParams)
+	if err != nil {
+		fmt.Printf("Error querying reports: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+
+	rtn := response{Reports: make([]Report, 0, len(rows))}
+	for _, row := range rows {
+		report := reportResponse(database.Report{
+			ID:         row.ID,
+			ReporterID: row.ReporterID,
+			UserID:     row.UserID,
+			ChirpID:    row.ChirpID,
+			ChirpBody:  row.ChirpBody,
+			Reason:     row.Reason,
+			Details:    row.Details,
+			Status:     row.Status,
+			CreatedAt:  row.CreatedAt,
+			ResolvedAt: row.ResolvedAt,
+		})
+		report.ReporterHandle = row.ReporterHandle.String
+		report.UserHandle = row.UserHandle.String
+		rtn.Reports = append(rtn.Reports, report)
+	}
+	if len(rows) == int(limit) {
+		last := rows[len(rows)-1]
+		rtn.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling reports: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Takes one of reportActions on an open report and closes it. suspend_user takes an optional duration like "72h",
+// without one the suspension doesn't end. Every action is written to the audit trail
+func (cfg *apiConfig) actOnReport(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action   string `json:"action"`
+		Note     string `json:"note"`
+		Duration string `json:"duration"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	moderatorID, err := cfg.moderatorFromRequest(r)
+	if err != nil {
+		status := 401
+		rtn := &returnErrors{Error: "Unauthorized"}
+		if errors.Is(err, errNotModerator) {
+			status = 403
+			rtn.Error = "Only moderators can act on reports"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal moderator auth error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		rtn := &returnErrors{Error: "Report not found"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal report ID error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(404)
+		w.Write(dat)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	invalid := ""
+	var suspendFor time.Duration
+	if err != nil {
+		invalid = "Unable to decode json POST request."
+	} else if !slices.Contains(reportActions, params.Action) {
+		invalid = fmt.Sprintf("action must be one of %s", strings.Join(reportActions, ", "))
+	} else if len([]rune(params.Note)) > maxModerationNoteLength {
+		invalid = fmt.Sprintf("note must be at most %d characters", maxModerationNoteLength)
+	} else if params.Duration != "" && params.Action != "suspend_user" {
+		invalid = "duration only applies to suspend_user"
+	} else if params.Duration != "" {
+		suspendFor, err = time.ParseDuration(params.Duration)
+		if err != nil || suspendFor <= 0 {
+			invalid = "duration must be a positive duration like 72h"
+		}
+	}
+	if invalid != "" {
+		rtn := &returnErrors{Error: invalid}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal report action validation error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	report, err := cfg.moderateReport(r.Context(), reportID, moderatorID, params.Action, params.Note, suspendFor)
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to act on report"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "Report not found"
+		} else if errors.Is(err, errReportClosed) {
+			status = 409
+			rtn.Error = "Report is already closed"
+		} else if errors.Is(err, errNoChirpToRemove) {
+			status = 400
+			rtn.Error = "Report has no chirp to remove"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal report action error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		if status == 503 {
+			fmt.Printf("Error acting on report: %s\n", err)
+		}
+		return
+	}
+
+	dat, err := json.Marshal(reportResponse(report))
+	if err != nil {
+		fmt.Printf("Error marshalling report: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Applies the action to an open report, writes it to the audit trail, closes the report and notifies the reporter,
+// all in one transaction. warn_user also notifies the reported user. moderatorID is invalid for the admin API key
+func (cfg *apiConfig) moderateReport(ctx context.Context, reportID uuid.UUID, moderatorID uuid.NullUUID, action, note string, suspendFor time.Duration) (database.Report, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Report{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	report, err := qtx.GetReportForUpdate(ctx, reportID)
+	if err != nil {
+		return database.Report{}, err
+	}
+	if report.Status != "open" {
+		return database.Report{}, errReportClosed
+	}
+
+	status := "resolved"
+	var notifications []database.Notification
+	switch action {
+	case "dismiss":
+		status = "dismissed"
+	case "remove_chirp":
+		//chirp_id is cleared when the author deletes the chirp before a moderator gets to it
+		if !report.ChirpID.Valid {
+			return database.Report{}, errNoChirpToRemove
+		}
+		chirp, err := qtx.GetSpecificChirp(ctx, report.ChirpID.UUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.Report{}, errNoChirpToRemove
+		}
+		if err != nil {
+			return database.Report{}, err
+		}
+		err = discardChirp(ctx, qtx, chirp)
+		if err != nil {
+			return database.Report{}, err
+		}
+	case "suspend_user":
+		until := sql.NullTime{}
+		if suspendFor > 0 {
+			until = sql.NullTime{Time: time.Now().Add(suspendFor).UTC(), Valid: true}
+		}
+		err = qtx.SuspendUser(ctx, database.SuspendUserParams{UserID: report.UserID, SuspendedUntil: until})
+		if err != nil {
+			return database.Report{}, err
+		}
+		//userIDFromRequest turns their access tokens away, revoking the sessions stops them being refreshed
+		err = qtx.RevokeAllSessionsForUser(ctx, report.UserID)
+		if err != nil {
+			return database.Report{}, err
+		}
+	case "warn_user":
+		warning, err := qtx.CreateModerationNotification(ctx, database.CreateModerationNotificationParams{
+			UserID:   report.UserID,
+			Type:     warningNotification,
+			ChirpID:  report.ChirpID,
+			ReportID: uuid.NullUUID{UUID: report.ID, Valid: true},
+		})
+		if err != nil {
+			return database.Report{}, err
+		}
+		notifications = append(notifications, warning)
+	}
+
+	closed, err := qtx.CloseReport(ctx, database.CloseReportParams{ID: report.ID, Status: status})
+	if err != nil {
+		return database.Report{}, err
+	}
+	err = qtx.LogModerationAction(ctx, database.LogModerationActionParams{
+		ModeratorID: moderatorID,
+		Action:      action,
+		ReportID:    uuid.NullUUID{UUID: report.ID, Valid: true},
+		UserID:      uuid.NullUUID{UUID: report.UserID, Valid: true},
+		ChirpID:     report.ChirpID,
+		Note:        note,
+	})
+	if err != nil {
+		return database.Report{}, err
+	}
+	//closed.ChirpID rather than report.ChirpID, remove_chirp has just cleared it
+	resolved, err := qtx.CreateModerationNotification(ctx, database.CreateModerationNotificationParams{
+		UserID:   report.ReporterID,
+		Type:     reportResolvedNotification,
+		ChirpID:  closed.ChirpID,
+		ReportID: uuid.NullUUID{UUID: report.ID, Valid: true},
+	})
+	if err != nil {
+		return database.Report{}, err
+	}
+	notifications = append(notifications, resolved)
+
+	for _, row := range notifications {
+		err = publishEvent(ctx, qtx, "notification", []string{notificationsTopic(row.UserID)}, moderationNotification(row, closed))
+		if err != nil {
+			return database.Report{}, err
+		}
+	}
+	return closed, tx.Commit()
+}
+
+// The audit trail of moderation actions newest first, ?report_id= and ?handle= narrow it to one report or user
+func (cfg *apiConfig) getModerationActions(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Actions    []ModerationAction `json:"actions"`
+		NextCursor string             `json:"next_cursor,omitempty"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	_, err := cfg.moderatorFromRequest(r)
+	if err != nil {
+		status := 401
+		rtn := &returnErrors{Error: "Unauthorized"}
+		if errors.Is(err, errNotModerator) {
+			status = 403
+			rtn.Error = "Only moderators can see moderation actions"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal moderator auth error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	limit, err := pageLimit(r, 50, 200)
+	if err != nil {
+		rtn := &returnErrors{Error: "limit must be a positive number"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal moderation actions limit error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	query := r.URL.Query()
+	listParams := database.GetModerationActionsParams{PageLimit: limit}
+	invalid := ""
+	if reportID := query.Get("report_id"); reportID != "" {
+		id, err := uuid.Parse(reportID)
+		if err != nil {
+			invalid = "report_id must be a UUID"
+		}
+		listParams.ReportID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			invalid = "Invalid cursor"
+		}
+		listParams.CursorCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		listParams.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if invalid != "" {
+		rtn := &returnErrors{Error: invalid}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal moderation actions filter error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	if handle := query.Get("handle"); handle != "" {
+		userID, err := cfg.database.GetUserIDByHandle(r.Context(), handle)
+		if err != nil {
+			status := 503
+			rtn := &returnErrors{Error: "Failed to query DB for user"}
+			if errors.Is(err, sql.ErrNoRows) {
+				status = 404
+				rtn.Error = "User not found"
+			}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal moderation actions user lookup error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write(dat)
+			return
+		}
+		listParams.UserID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+
+	rows, err := cfg.database.GetModerationActions(r.Context(), listParams)
+	if err != nil {
+		fmt.Printf("Error querying moderation actions: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+
+	rtn := response{Actions: make([]ModerationAction, 0, len(rows))}
+	for _, row := range rows {
+		action := ModerationAction{
+			ID:              row.ID,
+			ModeratorHandle: row.ModeratorHandle.String,
+			Action:          row.Action,
+			Note:            row.Note,
+			CreatedAt:       row.CreatedAt,
+		}
+		if row.ModeratorID.Valid {
+			moderatorID := row.ModeratorID.UUID
+			action.ModeratorID = &moderatorID
+		}
+		if row.ReportID.Valid {
+			reportID := row.ReportID.UUID
+			action.ReportID = &reportID
+		}
+		if row.UserID.Valid {
+			userID := row.UserID.UUID
+			action.UserID = &userID
+		}
+		if row.ChirpID.Valid {
+			chirpID := row.ChirpID.UUID
+			action.ChirpID = &chirpID
+		}
+		rtn.Actions = append(rtn.Actions, action)
+	}
+	if len(rows) == int(limit) {
+		last := rows[len(rows)-1]
+		rtn.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling moderation actions: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) addModerator(w http.ResponseWriter, r *http.Request) {
+	cfg.setModerator(w, r, true)
+}
+
+func (cfg *apiConfig) removeModerator(w http.ResponseWriter, r *http.Request) {
+	cfg.setModerator(w, r, false)
+}
+
+// Only the admin API key can add or remove moderators, both are idempotent and changes go in the audit trail
+func (cfg *apiConfig) setModerator(w http.ResponseWriter, r *http.Request, add bool) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	moderatorID, err := cfg.moderatorFromRequest(r)
+	if err != nil || moderatorID.Valid {
+		status := 403
+		rtn := &returnErrors{Error: "Only the admin API key can manage moderators"}
+		if err != nil && !errors.Is(err, errNotModerator) {
+			status = 401
+			rtn.Error = "Unauthorized"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal moderator management auth error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	userID, err := cfg.database.GetUserIDByHandle(r.Context(), r.PathValue("handle"))
+	if err == nil {
+		err = cfg.updateModerator(r.Context(), userID, add)
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to remove moderator"}
+		if add {
+			rtn.Error = "Failed to add moderator"
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "User not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal moderator management error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		if status == 503 {
+			fmt.Printf("Error updating moderator: %s\n", err)
+		}
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// adding a moderator that already is one, or removing a user that isn't, changes nothing and isn't logged
+func (cfg *apiConfig) updateModerator(ctx context.Context, userID uuid.UUID, add bool) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	action := "remove_moderator"
+	var changed int64
+	if add {
+		action = "add_moderator"
+		changed, err = qtx.AddModerator(ctx, userID)
+	} else {
+		changed, err = qtx.RemoveModerator(ctx, userID)
+	}
+	if err != nil || changed == 0 {
+		return err
+	}
+	err = qtx.LogModerationAction(ctx, database.LogModerationActionParams{
+		Action: action,
+		UserID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Works out who is moderating, an invalid UUID means the admin API key. Like webhookOwner a request that sends an
+// API key has to send the right one. errNotModerator means the user signed in fine but isn't a moderator
+func (cfg *apiConfig) moderatorFromRequest(r *http.Request) (uuid.NullUUID, error) {
+	if apiKey, err := auth.GetAPIKey(r.Header); err == nil {
+		if !auth.SecretsMatch(apiKey, cfg.adminAPIKey) {
+			return uuid.NullUUID{}, errors.New("invalid admin API key")
+		}
+		return uuid.NullUUID{}, nil
+	}
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	isModerator, err := cfg.database.IsModerator(r.Context(), userID)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	if !isModerator {
+		return uuid.NullUUID{}, errNotModerator
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}, nil
+}
+
+func reportResponse(row database.Report) Report {
+	report := Report{
+		ID:         row.ID,
+		ReporterID: row.ReporterID,
+		UserID:     row.UserID,
+		ChirpBody:  row.ChirpBody.String,
+		Reason:     row.Reason,
+		Details:    row.Details,
+		Status:     row.Status,
+		CreatedAt:  row.CreatedAt,
+	}
+	if row.ChirpID.Valid {
+		chirpID := row.ChirpID.UUID
+		report.ChirpID = &chirpID
+	}
+	if row.ResolvedAt.Valid {
+		resolvedAt := row.ResolvedAt.Time
+		report.ResolvedAt = &resolvedAt
+	}
+	return report
+}
+
+// the notification event for a warning or a resolved report, shaped like the ones GET /api/notifications returns
+func moderationNotification(row database.Notification, report database.Report) Notification {
+	reportID := report.ID
+	n := Notification{
+		ID:           row.ID,
+		Type:         row.Type,
+		ReportID:     &reportID,
+		ReportReason: report.Reason,
+		ReportStatus: report.Status,
+		CreatedAt:    row.CreatedAt,
+	}
+	if row.ChirpID.Valid {
+		chirpID := row.ChirpID.UUID
+		n.ChirpID = &chirpID
+	}
+	return n
+}
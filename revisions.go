@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"net/http"
+	"time"
+)
+
+// how long after posting a chirp its author can still edit it, CHIRP_EDIT_WINDOW overrides it
+const defaultChirpEditWindow = 15 * time.Minute
+
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// Replaces the body of one of the authenticated user's chirps while it's inside the edit window. The new body goes
+// through the same length and banned word checks as a new chirp and the old one is kept as a revision
+func (cfg *apiConfig) editChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	//an unparseable ID can't match a chirp, it's reported the same as a missing one
+	chirp := database.Chirp{}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		err = sql.ErrNoRows
+	} else {
+		chirp, err = cfg.database.GetSpecificChirp(r.Context(), chirpID)
+	}
+	if err != nil || chirp.UserID.UUID != userID || chirp.RechirpOfID.Valid || time.Since(chirp.CreatedAt) > cfg.chirpEditWindow {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for chirp"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "Chirp not found"
+		} else if err == nil && chirp.UserID.UUID != userID {
+			status = 403
+			rtn.Error = "You can only edit your own chirps"
+		} else if err == nil && chirp.RechirpOfID.Valid {
+			status = 400
+			rtn.Error = "Rechirps can't be edited"
+		} else if err == nil {
+			status = 403
+			rtn.Error = "The edit window for this chirp has closed"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal edit chirp lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil || len(params.Body) > maxChirpLength {
+		rtn := &returnErrors{Error: "Invalid request body"}
+		if err == nil {
+			rtn.Error = "chirp is too long"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal edit chirp params error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	edited, err := cfg.updateChirp(r.Context(), chirp.ID, censorChirp(params.Body))
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to edit chirp"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal edit chirp error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error editing chirp: %s\n", err)
+		return
+	}
+
+	chirps, err := cfg.chirpResponses(r.Context(), []database.Chirp{edited}, userID)
+	if err != nil || len(chirps) == 0 {
+		fmt.Printf("Error building edited chirp response: %v\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	dat, err := json.Marshal(chirps[0])
+	if err != nil {
+		fmt.Printf("Error marshalling edited chirp: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Saves the current body as a revision, writes the new one and re-indexes its entities in one transaction.
+// Newly mentioned users are notified, ones mentioned before aren't notified again
+func (cfg *apiConfig) updateChirp(ctx context.Context, chirpID uuid.UUID, body string) (database.Chirp, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	current, err := qtx.GetChirpForUpdate(ctx, chirpID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	err = qtx.AddChirpRevision(ctx, database.AddChirpRevisionParams{
+		ChirpID:   current.ID,
+		Body:      current.Body,
+		CreatedAt: current.UpdatedAt,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	chirp, err := qtx.UpdateChirpBody(ctx, database.UpdateChirpBodyParams{ID: current.ID, Body: body})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	err = qtx.ClearChirpEntities(ctx, chirp.ID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	err = indexChirpEntities(ctx, qtx, chirp)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	notifications, err := qtx.GetNewNotifications(ctx, chirp.UserID.UUID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	err = cfg.publishNotifications(ctx, qtx, notifications)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return chirp, tx.Commit()
+}
+
+// Lists the bodies a chirp had before its edits, most recently replaced first. A chirp that was never edited has none
+func (cfg *apiConfig) getChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		ChirpID   uuid.UUID       `json:"chirp_id"`
+		Revisions []ChirpRevision `json:"revisions"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	viewerID, _ := cfg.userIDFromRequest(r)
+	chirp := database.Chirp{}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		err = sql.ErrNoRows
+	} else {
+		chirp, err = cfg.visibleChirp(r.Context(), chirpID, viewerID)
+	}
+	var rows []database.ChirpRevision
+	if err == nil {
+		rows, err = cfg.database.GetChirpRevisions(r.Context(), chirp.ID)
+	}
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for chirp revisions"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "Chirp not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal chirp revisions error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	rtn := response{ChirpID: chirp.ID, Revisions: make([]ChirpRevision, 0, len(rows))}
+	for _, row := range rows {
+		rtn.Revisions = append(rtn.Revisions, ChirpRevision{
+			ID:         row.ID,
+			Body:       row.Body,
+			CreatedAt:  row.CreatedAt,
+			ReplacedAt: row.ReplacedAt,
+		})
+	}
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling chirp revisions: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/search"
+	"net/http"
+	"time"
+)
+
+// Highlighted is the body HTML escaped with matches wrapped in <mark>, the escaping happens before ts_headline adds the tags
+type SearchResult struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Body        string    `json:"body"`
+	UserID      uuid.UUID `json:"user_id"`
+	Rank        float32   `json:"rank"`
+	Highlighted string    `json:"highlighted"`
+}
+
+// Full text search over chirps, ranked by relevance, optionally filtered by author, paginated with an opaque cursor
+func (cfg *apiConfig) searchChirps(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Results    []SearchResult `json:"results"`
+		NextCursor string         `json:"next_cursor,omitempty"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	query := r.URL.Query()
+	tsQuery, err := search.ParseQuery(query.Get("q"))
+	if err != nil {
+		rtn := &returnErrors{Error: "Search query is empty"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal search query error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	limit, err := pageLimit(r, 20, 100)
+	if err != nil {
+		rtn := &returnErrors{Error: "limit must be a positive number"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal search limit error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	//signed in users don't get results from users they blocked, muted or were blocked by
+	viewerID, _ := cfg.userIDFromRequest(r)
+	searchParams := database.SearchChirpsParams{
+		Query:     tsQuery,
+		ViewerID:  uuid.NullUUID{UUID: viewerID, Valid: viewerID != uuid.Nil},
+		PageLimit: limit,
+	}
+	if authorID := query.Get("author_id"); authorID != "" {
+		parsed, err := uuid.Parse(authorID)
+		if err != nil {
+			rtn := &returnErrors{Error: "Invalid author_id"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal search author error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+		searchParams.AuthorID = uuid.NullUUID{UUID: parsed, Valid: true}
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		rank, id, err := search.DecodeCursor(cursor)
+		if err != nil {
+			rtn := &returnErrors{Error: "Invalid cursor"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal search cursor error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+		searchParams.CursorRank = sql.NullFloat64{Float64: float64(rank), Valid: true}
+		searchParams.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	rows, err := cfg.database.SearchChirps(r.Context(), searchParams)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to search chirps"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal search error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		fmt.Printf("Error searching chirps: %s\n", err)
+		return
+	}
+
+	rtn := response{Results: []SearchResult{}}
+	for _, row := range rows {
+		rtn.Results = append(rtn.Results, SearchResult{
+			ID:          row.ID,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			Body:        row.Body,
+			UserID:      row.UserID.UUID,
+			Rank:        row.Rank,
+			Highlighted: row.Highlighted,
+		})
+	}
+	//a full page means there may be more, hand back a cursor pointing after the last result
+	if len(rows) == int(limit) {
+		last := rows[len(rows)-1]
+		rtn.NextCursor = search.EncodeCursor(last.Rank, last.ID)
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling search results: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
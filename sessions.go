@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/auth"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"net"
+	"net/http"
+	"time"
+)
+
+// refresh tokens (sessions) live for 60 days unless revoked
+const sessionLifetime = 60 * 24 * time.Hour
+
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+}
+
+// Creates a new session for the user and returns the plain refresh token, only its hash is stored
+func (cfg *apiConfig) createSession(r *http.Request, userID uuid.UUID) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	sessionParams := database.CreateRefreshTokenParams{
+		TokenHash: auth.HashRefreshToken(refreshToken),
+		UserID:    userID,
+		ExpiresAt: time.Now().UTC().Add(sessionLifetime),
+		UserAgent: r.UserAgent(),
+		Ip:        clientIP(r),
+	}
+	_, err = cfg.database.CreateRefreshToken(r.Context(), sessionParams)
+	if err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
+// Remote address of the request without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Takes a refresh token in the Authorization header and returns a new access token
+func (cfg *apiConfig) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Token string `json:"token"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	refreshToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		rtn := &returnErrors{Error: "Missing refresh token"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal missing refresh token error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	session, err := cfg.database.GetActiveSessionByToken(r.Context(), auth.HashRefreshToken(refreshToken))
+	if err != nil {
+		rtn := &returnErrors{Error: "Invalid or expired refresh token"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal refresh token lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	touchParams := database.TouchSessionParams{ID: session.ID, Ip: clientIP(r), UserAgent: r.UserAgent()}
+	err = cfg.database.TouchSession(r.Context(), touchParams)
+	if err != nil {
+		fmt.Printf("Failed to update session last used time: %s\n", err)
+	}
+
+	token, err := auth.MakeJWT(session.UserID, cfg.jwtSecret, time.Hour)
+	if err != nil {
+		fmt.Printf("Error making JWT on refresh: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	dat, err := json.Marshal(&response{Token: token})
+	if err != nil {
+		fmt.Printf("Error marshalling refresh response: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Revokes the refresh token in the Authorization header, logging out the current session
+func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	refreshToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		rtn := &returnErrors{Error: "Missing refresh token"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal missing refresh token error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	_, err = cfg.database.RevokeSessionByToken(r.Context(), auth.HashRefreshToken(refreshToken))
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to revoke session"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal revoke error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// Lists the authenticated user's active sessions/devices
+func (cfg *apiConfig) listSessions(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	rows, err := cfg.database.ListActiveSessionsForUser(r.Context(), userID)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to query DB for sessions"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal session query error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		return
+	}
+
+	sessions := []Session{}
+	for _, row := range rows {
+		sessions = append(sessions, Session{
+			ID:         row.ID,
+			CreatedAt:  row.CreatedAt,
+			LastUsedAt: row.LastUsedAt,
+			ExpiresAt:  row.ExpiresAt,
+			UserAgent:  row.UserAgent,
+			IP:         row.Ip,
+		})
+	}
+
+	dat, err := json.Marshal(sessions)
+	if err != nil {
+		fmt.Printf("Error marshalling sessions: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Revokes one of the authenticated user's sessions by ID
+func (cfg *apiConfig) revokeSession(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		rtn := &returnErrors{Error: "Invalid session ID"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal session ID parse error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	//scoped to the user so one user can't revoke another user's session
+	revoked, err := cfg.database.RevokeSession(r.Context(), database.RevokeSessionParams{ID: sessionID, UserID: userID})
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to revoke session"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal revoke session error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		return
+	}
+	if revoked == 0 {
+		rtn := &returnErrors{Error: "Session not found"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal session not found error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(404)
+		w.Write(dat)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// Log out everywhere, revokes every active session of the authenticated user
+func (cfg *apiConfig) revokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	err = cfg.database.RevokeAllSessionsForUser(r.Context(), userID)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to revoke sessions"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal revoke all sessions error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		return
+	}
+	w.WriteHeader(204)
+}
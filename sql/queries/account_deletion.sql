@@ -0,0 +1,23 @@
+-- name: HardDeleteUser :exec
+DELETE FROM users WHERE id = $1;
+
+-- name: SoftDeleteUser :exec
+UPDATE users SET deleted_at = NOW(), purge_after = $2, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: RestoreUser :one
+UPDATE users SET deleted_at = NULL, purge_after = NULL, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NOT NULL AND purge_after > NOW()
+RETURNING *;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users WHERE deleted_at IS NOT NULL AND purge_after <= NOW();
+
+-- name: GetAccountStatus :one
+-- what decides whether a user's access token is still honoured
+SELECT users.deleted_at IS NOT NULL AS deleted, EXISTS (
+	SELECT 1 FROM user_suspensions
+	WHERE user_suspensions.user_id = users.id
+		AND (user_suspensions.suspended_until IS NULL OR user_suspensions.suspended_until > NOW())
+) AS suspended
+FROM users WHERE users.id = $1;
@@ -1,6 +1,5 @@
 -- name: AddChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (
-        gen_random_uuid(), NOW(), NOW(), $1, $2
-)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, quote_of_id, is_quote)
+SELECT new_chirp.id, NOW(), NOW(), sqlc.arg(body), sqlc.arg(user_id), sqlc.narg(in_reply_to_id), COALESCE(sqlc.narg(conversation_id)::uuid, new_chirp.id), sqlc.narg(quote_of_id)::uuid, sqlc.narg(quote_of_id)::uuid IS NOT NULL
+FROM (SELECT gen_random_uuid() AS id) AS new_chirp
 RETURNING *;
@@ -0,0 +1,64 @@
+-- name: BlockUser :execrows
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: MuteUser :execrows
+INSERT INTO user_mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (muter_id, muted_id) DO NOTHING;
+
+-- name: UnmuteUser :execrows
+DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: RemoveFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = sqlc.arg(user_id) AND followee_id = sqlc.arg(other_id))
+	OR (follower_id = sqlc.arg(other_id) AND followee_id = sqlc.arg(user_id));
+
+-- name: IsBlocked :one
+-- true when either user has blocked the other
+SELECT EXISTS (
+	SELECT 1 FROM blocks
+	WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = sqlc.arg(other_id))
+		OR (blocker_id = sqlc.arg(other_id) AND blocked_id = sqlc.arg(user_id))
+);
+
+-- name: GetBlockedUserIDs :many
+-- users the viewer blocked or was blocked by, mutes aren't included
+SELECT user_id FROM hidden_users WHERE viewer_id = $1 AND blocked;
+
+-- name: GetBlockedUsers :many
+SELECT users.id, users.handle, users.display_name, users.bio, users.is_chirpy_red, blocks.created_at
+FROM blocks
+JOIN users ON users.id = blocks.blocked_id
+WHERE blocks.blocker_id = sqlc.arg(user_id) AND users.deleted_at IS NULL
+	AND (
+		sqlc.narg(cursor_created_at)::timestamp IS NULL
+		OR (blocks.created_at, blocks.blocked_id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY blocks.created_at DESC, blocks.blocked_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetMutedUsers :many
+SELECT users.id, users.handle, users.display_name, users.bio, users.is_chirpy_red, user_mutes.created_at
+FROM user_mutes
+JOIN users ON users.id = user_mutes.muted_id
+WHERE user_mutes.muter_id = sqlc.arg(user_id) AND users.deleted_at IS NULL
+	AND (
+		sqlc.narg(cursor_created_at)::timestamp IS NULL
+		OR (user_mutes.created_at, user_mutes.muted_id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY user_mutes.created_at DESC, user_mutes.muted_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: IsBlockedByAny :one
+-- true when the user blocked or was blocked by any of the others
+SELECT EXISTS (
+	SELECT 1 FROM hidden_users
+	WHERE hidden_users.viewer_id = sqlc.arg(user_id) AND hidden_users.user_id = ANY(sqlc.arg(other_ids)::uuid[])
+		AND hidden_users.blocked
+);
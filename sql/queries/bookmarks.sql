@@ -0,0 +1,25 @@
+-- name: BookmarkChirp :execrows
+INSERT INTO bookmarks (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: RemoveBookmark :execrows
+DELETE FROM bookmarks WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetBookmarks :many
+-- bookmarks newest first, deleted accounts and users blocked in either direction are left out
+SELECT chirps.*, bookmarks.created_at AS bookmarked_at FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+JOIN users ON users.id = chirps.user_id
+WHERE bookmarks.user_id = sqlc.arg(user_id) AND users.deleted_at IS NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM hidden_users
+		WHERE hidden_users.viewer_id = bookmarks.user_id AND hidden_users.user_id = chirps.user_id
+			AND hidden_users.blocked
+	)
+	AND (
+		sqlc.narg(cursor_created_at)::timestamp IS NULL
+		OR (bookmarks.created_at, bookmarks.chirp_id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY bookmarks.created_at DESC, bookmarks.chirp_id DESC
+LIMIT sqlc.arg(page_limit);
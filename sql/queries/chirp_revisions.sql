@@ -0,0 +1,26 @@
+-- name: GetChirpForUpdate :one
+-- locks the row so concurrent edits are applied one after the other and each records the body it replaced
+SELECT * FROM chirps WHERE id = $1 FOR UPDATE;
+
+-- name: AddChirpRevision :exec
+INSERT INTO chirp_revisions (id, chirp_id, body, created_at, replaced_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW());
+
+-- name: UpdateChirpBody :one
+UPDATE chirps SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ClearChirpEntities :exec
+-- an edited body is indexed from scratch, the old hashtags, links and mentions go first
+WITH deleted_hashtags AS (
+	DELETE FROM chirp_hashtags WHERE chirp_hashtags.chirp_id = $1
+), deleted_links AS (
+	DELETE FROM chirp_links WHERE chirp_links.chirp_id = $1
+)
+DELETE FROM chirp_mentions WHERE chirp_mentions.chirp_id = $1;
+
+-- name: GetChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY replaced_at DESC;
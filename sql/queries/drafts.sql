@@ -0,0 +1,43 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, user_id, body, media_ids, publish_at, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW(), NOW())
+RETURNING *;
+
+-- name: GetDrafts :many
+SELECT * FROM drafts
+WHERE user_id = $1
+ORDER BY updated_at DESC;
+
+-- name: GetDraft :one
+SELECT * FROM drafts WHERE id = $1 AND user_id = $2;
+
+-- name: UpdateDraft :one
+UPDATE drafts SET body = $3, media_ids = $4, publish_at = $5, last_error = NULL, updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM drafts WHERE id = $1 AND user_id = $2;
+
+-- name: GetDraftForUpdate :one
+-- locks the draft so publishing it now can't race the scheduler, whichever gets the lock second finds it gone
+SELECT * FROM drafts WHERE id = $1 AND user_id = $2 FOR UPDATE;
+
+-- name: ClaimDueDraft :one
+-- SKIP LOCKED lets every instance run the scheduler, a draft another instance is publishing is passed over.
+-- Drafts of suspended users wait until the suspension ends
+SELECT drafts.* FROM drafts
+JOIN users ON users.id = drafts.user_id
+WHERE drafts.publish_at <= NOW() AND users.deleted_at IS NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM user_suspensions
+		WHERE user_suspensions.user_id = drafts.user_id
+			AND (user_suspensions.suspended_until IS NULL OR user_suspensions.suspended_until > NOW())
+	)
+ORDER BY drafts.publish_at
+LIMIT 1
+FOR UPDATE OF drafts SKIP LOCKED;
+
+-- name: UnscheduleDraft :exec
+UPDATE drafts SET publish_at = NULL, last_error = $2, updated_at = NOW()
+WHERE id = $1;
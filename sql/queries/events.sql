@@ -0,0 +1,18 @@
+-- name: InsertEvent :exec
+INSERT INTO events (type, topics, data, created_at)
+VALUES ($1, $2, $3, NOW());
+
+-- name: GetEvent :one
+SELECT * FROM events WHERE id = $1;
+
+-- name: GetEventsAfter :many
+SELECT * FROM events
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: GetLatestEventID :one
+SELECT COALESCE(MAX(id), 0)::bigint AS latest_id FROM events;
+
+-- name: PruneEvents :execrows
+DELETE FROM events WHERE created_at < $1;
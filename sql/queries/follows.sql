@@ -0,0 +1,64 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: BackfillTimeline :exec
+INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+SELECT sqlc.arg(follower_id)::uuid, chirps.id, chirps.user_id, chirps.created_at FROM chirps
+WHERE chirps.user_id = sqlc.arg(followee_id)::uuid
+ORDER BY chirps.created_at DESC
+LIMIT sqlc.arg(backfill_limit)
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: ClearTimelineAuthor :exec
+DELETE FROM timeline_entries WHERE user_id = $1 AND author_id = $2;
+
+-- name: GetFollowers :many
+SELECT users.id, users.handle, users.display_name, users.bio, users.is_chirpy_red, follows.created_at AS followed_at
+FROM follows
+JOIN users ON users.id = follows.follower_id
+WHERE follows.followee_id = sqlc.arg(user_id) AND users.deleted_at IS NULL
+	AND (
+		sqlc.narg(cursor_created_at)::timestamp IS NULL
+		OR (follows.created_at, follows.follower_id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY follows.created_at DESC, follows.follower_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetFollowing :many
+SELECT users.id, users.handle, users.display_name, users.bio, users.is_chirpy_red, follows.created_at AS followed_at
+FROM follows
+JOIN users ON users.id = follows.followee_id
+WHERE follows.follower_id = sqlc.arg(user_id) AND users.deleted_at IS NULL
+	AND (
+		sqlc.narg(cursor_created_at)::timestamp IS NULL
+		OR (follows.created_at, follows.followee_id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY follows.created_at DESC, follows.followee_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetTimeline :many
+SELECT chirps.* FROM timeline_entries
+JOIN chirps ON chirps.id = timeline_entries.chirp_id
+JOIN users ON users.id = timeline_entries.author_id
+WHERE timeline_entries.user_id = sqlc.arg(user_id) AND users.deleted_at IS NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM hidden_users
+		WHERE hidden_users.viewer_id = timeline_entries.user_id AND hidden_users.user_id = timeline_entries.author_id
+	)
+	AND (
+		sqlc.narg(cursor_created_at)::timestamp IS NULL
+		OR (timeline_entries.created_at, timeline_entries.chirp_id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY timeline_entries.created_at DESC, timeline_entries.chirp_id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetFollowerIDs :many
+-- followers who muted the user are left out, they don't get the user's chirps on their live timeline
+SELECT follower_id FROM follows
+WHERE followee_id = $1
+	AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE user_mutes.muter_id = follows.follower_id AND user_mutes.muted_id = $1);
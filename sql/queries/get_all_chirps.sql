@@ -1,2 +1,10 @@
 -- name: GetAllChirps :many
-SELECT * FROM chirps ORDER BY created_at ASC;
+-- users the viewer blocked, muted or was blocked by are left out, anonymous viewers see everyone
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deleted_at IS NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM hidden_users
+		WHERE hidden_users.viewer_id = sqlc.narg(viewer_id)::uuid AND hidden_users.user_id = chirps.user_id
+	)
+ORDER BY chirps.created_at ASC;
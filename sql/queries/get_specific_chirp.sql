@@ -1,2 +1,4 @@
 -- name: GetSpecificChirp :one
-SELECT * FROM chirps WHERE id=$1;
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id=$1 AND users.deleted_at IS NULL;
@@ -0,0 +1,33 @@
+-- name: UpsertHashtag :one
+INSERT INTO hashtags (id, tag, created_at)
+VALUES (gen_random_uuid(), $1, NOW())
+ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag
+RETURNING *;
+
+-- name: AddChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id, start_offset, end_offset)
+VALUES ($1, $2, $3, $4);
+
+-- name: AddChirpMention :exec
+INSERT INTO chirp_mentions (chirp_id, handle, start_offset, end_offset)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetChirpsByHashtag :many
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deleted_at IS NULL
+	AND EXISTS (
+		SELECT 1 FROM chirp_hashtags
+		JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+		WHERE chirp_hashtags.chirp_id = chirps.id AND hashtags.tag = sqlc.arg(tag)
+	)
+	AND NOT EXISTS (
+		SELECT 1 FROM hidden_users
+		WHERE hidden_users.viewer_id = sqlc.narg(viewer_id)::uuid AND hidden_users.user_id = chirps.user_id
+	)
+	AND (
+		sqlc.narg(cursor_created_at)::timestamp IS NULL
+		OR (chirps.created_at, chirps.id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_limit);
@@ -0,0 +1,42 @@
+-- name: QueueLinkPreview :exec
+-- new URLs are queued, cached previews are only fetched again once they're a week old
+INSERT INTO link_previews (url, status, next_fetch_at, created_at, updated_at)
+VALUES ($1, 'pending', NOW(), NOW(), NOW())
+ON CONFLICT (url) DO UPDATE SET status = 'pending', attempts = 0, next_fetch_at = NOW(), updated_at = NOW()
+WHERE link_previews.status <> 'pending' AND link_previews.fetched_at < NOW() - INTERVAL '7 days';
+
+-- name: AddChirpLink :exec
+INSERT INTO chirp_links (chirp_id, url, start_offset, end_offset)
+VALUES ($1, $2, $3, $4);
+
+-- name: ClaimLinkPreviews :many
+-- due previews are leased for five minutes, a worker that dies mid fetch leaves them to be retried after that
+UPDATE link_previews
+SET next_fetch_at = NOW() + INTERVAL '5 minutes', updated_at = NOW()
+WHERE url IN (
+	SELECT due.url FROM link_previews due
+	WHERE due.status = 'pending' AND due.next_fetch_at <= NOW()
+	ORDER BY due.next_fetch_at
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING url, attempts;
+
+-- name: SaveLinkPreview :exec
+UPDATE link_previews
+SET status = 'ok', title = $2, description = $3, image_url = $4, site_name = $5, attempts = 0, last_error = NULL,
+	next_fetch_at = NULL, fetched_at = NOW(), updated_at = NOW()
+WHERE url = $1;
+
+-- name: FailLinkPreview :exec
+UPDATE link_previews
+SET status = $2, attempts = attempts + 1, last_error = $3, next_fetch_at = $4, fetched_at = NOW(), updated_at = NOW()
+WHERE url = $1;
+
+-- name: GetChirpLinkPreviews :many
+SELECT chirp_links.chirp_id, link_previews.url, link_previews.title, link_previews.description,
+	link_previews.image_url, link_previews.site_name
+FROM chirp_links
+JOIN link_previews ON link_previews.url = chirp_links.url
+WHERE chirp_links.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]) AND link_previews.status = 'ok'
+ORDER BY chirp_links.chirp_id, chirp_links.start_offset;
@@ -0,0 +1,64 @@
+-- name: CreateList :one
+INSERT INTO lists (id, user_id, name, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, NOW(), NOW())
+RETURNING *;
+
+-- name: GetLists :many
+SELECT lists.*, (
+	SELECT COUNT(*) FROM list_members
+	JOIN users ON users.id = list_members.user_id
+	WHERE list_members.list_id = lists.id AND users.deleted_at IS NULL
+) AS member_count
+FROM lists
+WHERE lists.user_id = $1
+ORDER BY lists.created_at DESC;
+
+-- name: CountListMembers :one
+-- members whose accounts are deleted aren't shown or counted
+SELECT COUNT(*) FROM list_members
+JOIN users ON users.id = list_members.user_id
+WHERE list_members.list_id = $1 AND users.deleted_at IS NULL;
+
+-- name: GetList :one
+SELECT * FROM lists WHERE id = $1 AND user_id = $2;
+
+-- name: RenameList :one
+UPDATE lists SET name = $3, updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteList :execrows
+DELETE FROM lists WHERE id = $1 AND user_id = $2;
+
+-- name: AddListMember :exec
+INSERT INTO list_members (list_id, user_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (list_id, user_id) DO NOTHING;
+
+-- name: RemoveListMember :exec
+DELETE FROM list_members WHERE list_id = $1 AND user_id = $2;
+
+-- name: GetListMembers :many
+SELECT users.id, users.handle, users.display_name, users.bio, users.is_chirpy_red, list_members.created_at AS added_at
+FROM list_members
+JOIN users ON users.id = list_members.user_id
+WHERE list_members.list_id = $1 AND users.deleted_at IS NULL
+ORDER BY list_members.created_at DESC, list_members.user_id DESC;
+
+-- name: GetListChirps :many
+-- newest chirps by the list's members, deleted accounts and users the owner blocked or muted are left out
+SELECT chirps.* FROM chirps
+JOIN list_members ON list_members.user_id = chirps.user_id
+JOIN lists ON lists.id = list_members.list_id
+JOIN users ON users.id = chirps.user_id
+WHERE list_members.list_id = sqlc.arg(list_id) AND users.deleted_at IS NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM hidden_users
+		WHERE hidden_users.viewer_id = lists.user_id AND hidden_users.user_id = chirps.user_id
+	)
+	AND (
+		sqlc.narg(cursor_created_at)::timestamp IS NULL
+		OR (chirps.created_at, chirps.id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_limit);
@@ -1,2 +1,2 @@
 -- name: UserandHashLookup :one
-SELECT * FROM users WHERE email=$1;
+SELECT * FROM users WHERE lower(email) = lower($1);
@@ -0,0 +1,23 @@
+-- name: CreateMedia :one
+INSERT INTO media (id, user_id, content_type, width, height, size_bytes, storage_key, thumbnail_key, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+RETURNING *;
+
+-- name: GetMedia :one
+SELECT * FROM media WHERE id = $1;
+
+-- name: CountAttachableMedia :one
+-- media the user uploaded that isn't on a chirp yet
+SELECT COUNT(*) FROM media
+WHERE media.user_id = $1 AND media.id = ANY(sqlc.arg(media_ids)::uuid[])
+AND NOT EXISTS (SELECT 1 FROM chirp_media WHERE chirp_media.media_id = media.id);
+
+-- name: AttachChirpMedia :exec
+INSERT INTO chirp_media (chirp_id, media_id, position)
+VALUES ($1, $2, $3);
+
+-- name: GetChirpMedia :many
+SELECT chirp_media.chirp_id, media.* FROM chirp_media
+JOIN media ON media.id = chirp_media.media_id
+WHERE chirp_media.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_media.chirp_id, chirp_media.position;
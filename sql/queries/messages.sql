@@ -0,0 +1,80 @@
+-- name: CreateConversation :one
+-- a second one-to-one conversation between the same pair conflicts on direct_key and returns no row
+INSERT INTO conversations (id, direct_key, created_at, last_message_at)
+VALUES (gen_random_uuid(), $1, NOW(), NOW())
+ON CONFLICT (direct_key) DO NOTHING
+RETURNING *;
+
+-- name: GetDirectConversation :one
+SELECT * FROM conversations WHERE direct_key = $1;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+VALUES ($1, $2, NOW());
+
+-- name: GetConversation :one
+-- only members can see a conversation, anyone else gets no row
+SELECT conversations.* FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversations.id = $1 AND conversation_members.user_id = $2;
+
+-- name: GetConversations :many
+-- most recently active first, unread_count leaves out the user's own messages and those from users blocked either way
+SELECT conversations.*, (
+	SELECT COUNT(*) FROM messages
+	WHERE messages.conversation_id = conversations.id AND messages.sender_id <> conversation_members.user_id
+		AND (conversation_members.last_read_at IS NULL OR messages.created_at > conversation_members.last_read_at)
+		AND NOT EXISTS (
+			SELECT 1 FROM hidden_users
+			WHERE hidden_users.viewer_id = conversation_members.user_id AND hidden_users.user_id = messages.sender_id
+				AND hidden_users.blocked
+		)
+) AS unread_count
+FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = sqlc.arg(user_id)
+	AND (
+		sqlc.narg(cursor_created_at)::timestamp IS NULL
+		OR (conversations.last_message_at, conversations.id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY conversations.last_message_at DESC, conversations.id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: GetConversationMembers :many
+-- members whose accounts are deleted aren't shown
+SELECT conversation_members.conversation_id, users.id, users.handle, users.display_name
+FROM conversation_members
+JOIN users ON users.id = conversation_members.user_id
+WHERE conversation_members.conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[]) AND users.deleted_at IS NULL
+ORDER BY conversation_members.joined_at, users.id;
+
+-- name: GetConversationMemberIDs :many
+SELECT user_id FROM conversation_members WHERE conversation_id = $1;
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, conversation_id, sender_id, body, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW())
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations SET last_message_at = $2 WHERE id = $1;
+
+-- name: MarkConversationRead :execrows
+UPDATE conversation_members SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: GetMessages :many
+-- newest first, messages from users the viewer blocked or was blocked by are left out
+SELECT messages.* FROM messages
+WHERE messages.conversation_id = sqlc.arg(conversation_id)
+	AND NOT EXISTS (
+		SELECT 1 FROM hidden_users
+		WHERE hidden_users.viewer_id = sqlc.arg(viewer_id) AND hidden_users.user_id = messages.sender_id
+			AND hidden_users.blocked
+	)
+	AND (
+		sqlc.narg(cursor_created_at)::timestamp IS NULL
+		OR (messages.created_at, messages.id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY messages.created_at DESC, messages.id DESC
+LIMIT sqlc.arg(page_limit);
@@ -0,0 +1,63 @@
+-- name: GetNotifications :many
+SELECT notifications.id, notifications.type, notifications.chirp_id, notifications.created_at, notifications.read_at,
+	notifications.actor_id, users.handle AS actor_handle, notifications.report_id, reports.reason AS report_reason,
+	reports.status AS report_status
+FROM notifications
+LEFT JOIN users ON users.id = notifications.actor_id
+LEFT JOIN reports ON reports.id = notifications.report_id
+WHERE notifications.user_id = sqlc.arg(user_id) AND (notifications.actor_id IS NULL OR users.deleted_at IS NULL)
+	AND NOT EXISTS (
+		SELECT 1 FROM hidden_users
+		WHERE hidden_users.viewer_id = notifications.user_id AND hidden_users.user_id = notifications.actor_id
+	)
+	AND (NOT sqlc.arg(unread_only)::bool OR notifications.read_at IS NULL)
+	AND (
+		sqlc.narg(cursor_created_at)::timestamp IS NULL
+		OR (notifications.created_at, notifications.id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY notifications.created_at DESC, notifications.id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+LEFT JOIN users ON users.id = notifications.actor_id
+WHERE notifications.user_id = $1 AND notifications.read_at IS NULL
+	AND (notifications.actor_id IS NULL OR users.deleted_at IS NULL)
+	AND NOT EXISTS (
+		SELECT 1 FROM hidden_users
+		WHERE hidden_users.viewer_id = notifications.user_id AND hidden_users.user_id = notifications.actor_id
+	);
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = sqlc.arg(user_id) AND read_at IS NULL AND id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: GetMutedNotificationTypes :many
+SELECT type FROM notification_mutes WHERE user_id = $1;
+
+-- name: MuteNotificationType :exec
+INSERT INTO notification_mutes (user_id, type)
+VALUES ($1, $2)
+ON CONFLICT (user_id, type) DO NOTHING;
+
+-- name: UnmuteNotificationType :exec
+DELETE FROM notification_mutes WHERE user_id = $1 AND type = $2;
+
+-- name: GetNewNotifications :many
+-- NOW() is the transaction start time, so inside the transaction that did the liking, following or posting
+-- this finds exactly the notifications its triggers just wrote
+SELECT notifications.id, notifications.user_id, notifications.type, notifications.chirp_id, notifications.created_at,
+	notifications.actor_id, users.handle AS actor_handle
+FROM notifications
+JOIN users ON users.id = notifications.actor_id
+WHERE notifications.actor_id = sqlc.arg(actor_id)::uuid AND notifications.created_at = NOW();
+
+-- name: CreateModerationNotification :one
+-- moderation notifications have no actor and skip create_notification, they can't be muted
+INSERT INTO notifications (id, user_id, type, chirp_id, report_id, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW())
+RETURNING *;
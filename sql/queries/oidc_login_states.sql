@@ -0,0 +1,10 @@
+-- name: CreateOIDCLoginState :exec
+INSERT INTO oidc_login_states (state, nonce, code_verifier, expires_at, restore)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ConsumeOIDCLoginState :one
+DELETE FROM oidc_login_states WHERE state = $1 AND expires_at > NOW()
+RETURNING *;
+
+-- name: DeleteExpiredOIDCLoginStates :exec
+DELETE FROM oidc_login_states WHERE expires_at <= NOW();
@@ -0,0 +1,61 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, user_id, url, secret, event_types, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW(), NOW())
+RETURNING *;
+
+-- name: GetWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE user_id IS NOT DISTINCT FROM $1
+ORDER BY created_at;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND user_id IS NOT DISTINCT FROM $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at, updated_at)
+SELECT gen_random_uuid(), webhook_endpoints.id, sqlc.arg(event_id), sqlc.arg(event_type)::text, sqlc.arg(payload), 'pending', 0, NOW(), NOW(), NOW()
+FROM webhook_endpoints
+WHERE sqlc.arg(event_type)::text = ANY(webhook_endpoints.event_types)
+AND (webhook_endpoints.user_id IS NULL OR webhook_endpoints.user_id = sqlc.arg(subject_user_id));
+
+-- name: ClaimWebhookDeliveries :many
+-- due deliveries are leased for five minutes, a worker that dies mid send leaves them to be retried after that
+UPDATE webhook_deliveries
+SET next_attempt_at = NOW() + INTERVAL '5 minutes', updated_at = NOW()
+FROM webhook_endpoints
+WHERE webhook_endpoints.id = webhook_deliveries.endpoint_id
+AND webhook_deliveries.id IN (
+	SELECT due.id FROM webhook_deliveries due
+	WHERE due.status = 'pending' AND due.next_attempt_at <= NOW()
+	ORDER BY due.next_attempt_at
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING webhook_deliveries.id, webhook_deliveries.event_type, webhook_deliveries.payload, webhook_deliveries.attempts,
+	webhook_endpoints.url, webhook_endpoints.secret;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered', attempts = attempts + 1, last_status_code = $2, last_error = NULL,
+	next_attempt_at = NULL, delivered_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkWebhookFailed :exec
+UPDATE webhook_deliveries
+SET status = $2, attempts = attempts + 1, last_status_code = $3, last_error = $4, next_attempt_at = $5, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetWebhookDeadLetters :many
+SELECT * FROM webhook_dead_letters
+WHERE user_id IS NOT DISTINCT FROM $1
+ORDER BY updated_at DESC
+LIMIT $2;
+
+-- name: RedeliverWebhook :execrows
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), last_error = NULL, updated_at = NOW()
+FROM webhook_endpoints
+WHERE webhook_deliveries.id = $1
+AND webhook_endpoints.id = webhook_deliveries.endpoint_id
+AND webhook_endpoints.user_id IS NOT DISTINCT FROM $2;
@@ -0,0 +1,8 @@
+-- name: RecordPaymentEvent :execrows
+INSERT INTO payment_events (id, event_type, received_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (id) DO NOTHING;
+
+-- name: SetChirpyRed :execrows
+UPDATE users SET is_chirpy_red = $2, updated_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,28 @@
+-- name: CreatePoll :exec
+INSERT INTO polls (chirp_id, closes_at, created_at)
+VALUES ($1, $2, NOW());
+
+-- name: AddPollOption :exec
+INSERT INTO poll_options (id, chirp_id, position, label)
+VALUES (gen_random_uuid(), $1, $2, $3);
+
+-- name: GetPoll :one
+SELECT * FROM polls WHERE chirp_id = $1;
+
+-- name: CastPollVote :execrows
+-- the closing time is checked by the insert itself so a vote can't slip in after the poll closes,
+-- no rows are inserted when the poll has closed or the user already voted
+INSERT INTO poll_votes (chirp_id, user_id, option_id, created_at)
+SELECT polls.chirp_id, $2, $3, NOW() FROM polls
+WHERE polls.chirp_id = $1 AND polls.closes_at > NOW()
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: GetChirpPollOptions :many
+SELECT poll_options.*, polls.closes_at FROM poll_options
+JOIN polls ON polls.chirp_id = poll_options.chirp_id
+WHERE poll_options.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY poll_options.chirp_id, poll_options.position;
+
+-- name: GetPollVotes :many
+SELECT chirp_id, option_id FROM poll_votes
+WHERE user_id = $1 AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
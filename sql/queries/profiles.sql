@@ -0,0 +1,19 @@
+-- name: UpdateUserProfile :one
+UPDATE users SET handle = $2, display_name = $3, bio = $4, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetPublicProfile :one
+SELECT users.id, users.created_at, users.handle, users.display_name, users.bio, users.is_chirpy_red,
+	(SELECT COUNT(*) FROM chirps WHERE chirps.user_id = users.id) AS chirp_count,
+	(SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.id) AS follower_count,
+	(SELECT COUNT(*) FROM follows WHERE follows.follower_id = users.id) AS following_count
+FROM users
+WHERE lower(users.handle) = lower($1) AND users.deleted_at IS NULL;
+
+-- name: GetUserIDByHandle :one
+SELECT id FROM users
+WHERE lower(handle) = lower($1) AND deleted_at IS NULL;
+
+-- name: GetUserHandles :many
+SELECT id, handle FROM users WHERE id = ANY($1::uuid[]);
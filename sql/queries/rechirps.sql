@@ -0,0 +1,14 @@
+-- name: AddRechirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, conversation_id, rechirp_of_id)
+SELECT new_chirp.id, NOW(), NOW(), '', sqlc.arg(user_id), new_chirp.id, sqlc.arg(rechirp_of_id)
+FROM (SELECT gen_random_uuid() AS id) AS new_chirp
+ON CONFLICT (user_id, rechirp_of_id) WHERE rechirp_of_id IS NOT NULL DO NOTHING
+RETURNING *;
+
+-- name: GetRechirp :one
+SELECT * FROM chirps
+WHERE user_id = $1 AND rechirp_of_id = $2;
+
+-- name: DeleteRechirp :execrows
+DELETE FROM chirps
+WHERE user_id = $1 AND rechirp_of_id = $2;
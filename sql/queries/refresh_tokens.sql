@@ -0,0 +1,31 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (id, token_hash, created_at, updated_at, user_id, expires_at, user_agent, ip, last_used_at)
+VALUES (
+	gen_random_uuid(), $1, NOW(), NOW(), $2, $3, $4, $5, NOW()
+)
+RETURNING *;
+
+-- name: GetActiveSessionByToken :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW();
+
+-- name: TouchSession :exec
+UPDATE refresh_tokens SET last_used_at = NOW(), updated_at = NOW(), ip = $2, user_agent = $3
+WHERE id = $1;
+
+-- name: ListActiveSessionsForUser :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+ORDER BY last_used_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeSessionByToken :execrows
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW()
+WHERE token_hash = $1 AND revoked_at IS NULL;
+
+-- name: RevokeAllSessionsForUser :exec
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
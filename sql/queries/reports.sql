@@ -0,0 +1,82 @@
+-- name: CreateReport :one
+-- a reporter can only have one open report about the same chirp or user, a repeat inserts nothing and returns no row
+INSERT INTO reports (id, reporter_id, user_id, chirp_id, chirp_body, reason, details, status, created_at)
+SELECT gen_random_uuid(), sqlc.arg(reporter_id)::uuid, sqlc.arg(user_id)::uuid, sqlc.narg(chirp_id)::uuid,
+	sqlc.narg(chirp_body)::text, sqlc.arg(reason)::text, sqlc.arg(details)::text, 'open', NOW()
+WHERE NOT EXISTS (
+	SELECT 1 FROM reports
+	WHERE reporter_id = sqlc.arg(reporter_id)::uuid AND user_id = sqlc.arg(user_id)::uuid
+		AND chirp_id IS NOT DISTINCT FROM sqlc.narg(chirp_id)::uuid AND status = 'open'
+)
+RETURNING *;
+
+-- name: GetReportForUpdate :one
+-- locks the report so two moderators acting on it at once can't both act, the second finds it closed
+SELECT * FROM reports WHERE id = $1 FOR UPDATE;
+
+-- name: CloseReport :one
+UPDATE reports SET status = $2, resolved_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetReports :many
+-- the moderation queue, oldest first so reports are worked in the order they came in
+SELECT reports.*, reporters.handle AS reporter_handle, reported.handle AS user_handle
+FROM reports
+JOIN users reporters ON reporters.id = reports.reporter_id
+JOIN users reported ON reported.id = reports.user_id
+WHERE reports.status = sqlc.arg(status)
+	AND (sqlc.narg(reason)::text IS NULL OR reports.reason = sqlc.narg(reason)::text)
+	AND (sqlc.narg(user_id)::uuid IS NULL OR reports.user_id = sqlc.narg(user_id)::uuid)
+	AND (sqlc.narg(chirp_id)::uuid IS NULL OR reports.chirp_id = sqlc.narg(chirp_id)::uuid)
+	AND (
+		sqlc.narg(cursor_created_at)::timestamp IS NULL
+		OR (reports.created_at, reports.id) > (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY reports.created_at, reports.id
+LIMIT sqlc.arg(page_limit);
+
+-- name: LogModerationAction :exec
+INSERT INTO moderation_actions (id, moderator_id, action, report_id, user_id, chirp_id, note, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, NOW());
+
+-- name: GetModerationActions :many
+-- newest first, moderator_handle is empty for actions taken with the admin API key
+SELECT moderation_actions.*, users.handle AS moderator_handle
+FROM moderation_actions
+LEFT JOIN users ON users.id = moderation_actions.moderator_id
+WHERE (sqlc.narg(report_id)::uuid IS NULL OR moderation_actions.report_id = sqlc.narg(report_id)::uuid)
+	AND (sqlc.narg(user_id)::uuid IS NULL OR moderation_actions.user_id = sqlc.narg(user_id)::uuid)
+	AND (
+		sqlc.narg(cursor_created_at)::timestamp IS NULL
+		OR (moderation_actions.created_at, moderation_actions.id) < (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY moderation_actions.created_at DESC, moderation_actions.id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: AddModerator :execrows
+INSERT INTO moderators (user_id, created_at)
+VALUES ($1, NOW())
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: RemoveModerator :execrows
+DELETE FROM moderators WHERE user_id = $1;
+
+-- name: IsModerator :one
+SELECT EXISTS (
+	SELECT 1 FROM moderators
+	JOIN users ON users.id = moderators.user_id
+	WHERE moderators.user_id = $1 AND users.deleted_at IS NULL
+);
+
+-- name: SuspendUser :exec
+-- suspending an already suspended user replaces the old suspension
+INSERT INTO user_suspensions (user_id, suspended_at, suspended_until)
+VALUES ($1, NOW(), $2)
+ON CONFLICT (user_id) DO UPDATE SET suspended_at = NOW(), suspended_until = EXCLUDED.suspended_until;
+
+-- name: IsSuspended :one
+SELECT EXISTS (
+	SELECT 1 FROM user_suspensions
+	WHERE user_id = $1 AND (suspended_until IS NULL OR suspended_until > NOW())
+);
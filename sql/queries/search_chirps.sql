@@ -0,0 +1,24 @@
+-- name: SearchChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id,
+	ts_rank(chirps.search_vector, to_tsquery('english', sqlc.arg(query)))::real AS rank,
+	ts_headline(
+		'english',
+		replace(replace(replace(replace(replace(chirps.body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;'),
+		to_tsquery('english', sqlc.arg(query)),
+		'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'
+	)::text AS highlighted
+FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deleted_at IS NULL
+	AND chirps.search_vector @@ to_tsquery('english', sqlc.arg(query))
+	AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id)::uuid)
+	AND NOT EXISTS (
+		SELECT 1 FROM hidden_users
+		WHERE hidden_users.viewer_id = sqlc.narg(viewer_id)::uuid AND hidden_users.user_id = chirps.user_id
+	)
+	AND (
+		sqlc.narg(cursor_rank)::real IS NULL
+		OR (ts_rank(chirps.search_vector, to_tsquery('english', sqlc.arg(query)))::real, chirps.id) < (sqlc.narg(cursor_rank)::real, sqlc.narg(cursor_id)::uuid)
+	)
+ORDER BY rank DESC, chirps.id DESC
+LIMIT sqlc.arg(page_limit);
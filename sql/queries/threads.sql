@@ -0,0 +1,54 @@
+-- name: GetChirpsByIDs :many
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = ANY($1::uuid[]) AND users.deleted_at IS NULL;
+
+-- name: GetChirpAncestorIDs :many
+WITH RECURSIVE ancestors AS (
+	SELECT parent.id, parent.in_reply_to_id, 1 AS depth
+	FROM chirps AS child
+	JOIN chirps AS parent ON parent.id = child.in_reply_to_id
+	WHERE child.id = $1
+	UNION ALL
+	SELECT chirps.id, chirps.in_reply_to_id, ancestors.depth + 1
+	FROM chirps
+	JOIN ancestors ON chirps.id = ancestors.in_reply_to_id
+	WHERE ancestors.depth < 100
+)
+SELECT id, depth FROM ancestors
+ORDER BY depth DESC;
+
+-- name: GetReplyTree :many
+-- every reply below a chirp up to max_depth, each chirp's replies are cut to the oldest child_limit and the whole tree to
+-- node_limit rows. The cut keeps whole levels first so every top level reply is returned
+WITH RECURSIVE tree AS (
+	SELECT top_level.id, top_level.in_reply_to_id, top_level.created_at, 1 AS depth
+	FROM (
+		SELECT chirps.id, chirps.in_reply_to_id, chirps.created_at FROM chirps
+		WHERE chirps.in_reply_to_id = sqlc.arg(chirp_id)
+			AND (
+				sqlc.narg(cursor_created_at)::timestamp IS NULL
+				OR (chirps.created_at, chirps.id) > (sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid)
+			)
+		ORDER BY chirps.created_at ASC, chirps.id ASC
+		LIMIT sqlc.arg(page_limit)
+	) AS top_level
+	UNION ALL
+	SELECT replies.id, replies.in_reply_to_id, replies.created_at, tree.depth + 1
+	FROM tree
+	CROSS JOIN LATERAL (
+		SELECT chirps.id, chirps.in_reply_to_id, chirps.created_at FROM chirps
+		WHERE chirps.in_reply_to_id = tree.id
+		ORDER BY chirps.created_at ASC, chirps.id ASC
+		LIMIT sqlc.arg(child_limit)
+	) AS replies
+	WHERE tree.depth < sqlc.arg(max_depth)::int
+)
+SELECT id, in_reply_to_id, created_at, depth FROM tree
+ORDER BY depth, in_reply_to_id, created_at, id
+LIMIT sqlc.arg(node_limit);
+
+-- name: GetReplyCounts :many
+SELECT in_reply_to_id, COUNT(*) AS reply_count FROM chirps
+WHERE in_reply_to_id = ANY($1::uuid[])
+GROUP BY in_reply_to_id;
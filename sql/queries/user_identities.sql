@@ -0,0 +1,11 @@
+-- name: GetUserByIdentity :one
+SELECT users.* FROM users
+JOIN user_identities ON user_identities.user_id = users.id
+WHERE user_identities.issuer = $1 AND user_identities.subject = $2;
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (id, created_at, updated_at, user_id, issuer, subject)
+VALUES (
+	gen_random_uuid(), NOW(), NOW(), $1, $2, $3
+)
+RETURNING *;
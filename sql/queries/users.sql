@@ -4,3 +4,6 @@ VALUES (
 	gen_random_uuid(), NOW(), NOW(), $1, $2
 )
 RETURNING *;
+
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1;
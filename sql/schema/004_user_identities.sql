@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE user_identities(
+id UUID PRIMARY KEY,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+user_id UUID NOT NULL,
+issuer TEXT NOT NULL,
+subject TEXT NOT NULL,
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+UNIQUE (issuer, subject)
+);
+
+CREATE TABLE oidc_login_states(
+state TEXT PRIMARY KEY,
+nonce TEXT NOT NULL,
+code_verifier TEXT NOT NULL,
+expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE oidc_login_states;
+DROP TABLE user_identities;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE refresh_tokens(
+id UUID PRIMARY KEY,
+token_hash TEXT NOT NULL UNIQUE,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+user_id UUID NOT NULL,
+expires_at TIMESTAMP NOT NULL,
+revoked_at TIMESTAMP,
+user_agent TEXT NOT NULL DEFAULT '',
+ip TEXT NOT NULL DEFAULT '',
+last_used_at TIMESTAMP NOT NULL,
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens(user_id);
+
+-- +goose Down
+DROP TABLE refresh_tokens;
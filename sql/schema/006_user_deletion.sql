@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN purge_after TIMESTAMP;
+
+CREATE INDEX users_purge_after_idx ON users(purge_after) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX users_purge_after_idx;
+ALTER TABLE users DROP COLUMN purge_after;
+ALTER TABLE users DROP COLUMN deleted_at;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN is_chirpy_red BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE payment_events(
+id TEXT PRIMARY KEY,
+event_type TEXT NOT NULL,
+received_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE payment_events;
+ALTER TABLE users DROP COLUMN is_chirpy_red;
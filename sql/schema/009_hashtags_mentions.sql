@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE hashtags(
+id UUID PRIMARY KEY,
+tag TEXT NOT NULL UNIQUE,
+created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE chirp_hashtags(
+chirp_id UUID NOT NULL,
+hashtag_id UUID NOT NULL,
+start_offset INTEGER NOT NULL,
+end_offset INTEGER NOT NULL,
+PRIMARY KEY (chirp_id, start_offset),
+FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE,
+FOREIGN KEY (hashtag_id) REFERENCES hashtags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_hashtags_hashtag_id_idx ON chirp_hashtags(hashtag_id);
+
+CREATE TABLE chirp_mentions(
+chirp_id UUID NOT NULL,
+handle TEXT NOT NULL,
+start_offset INTEGER NOT NULL,
+end_offset INTEGER NOT NULL,
+PRIMARY KEY (chirp_id, start_offset),
+FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_mentions_handle_idx ON chirp_mentions(lower(handle));
+
+-- +goose Down
+DROP TABLE chirp_mentions;
+DROP TABLE chirp_hashtags;
+DROP TABLE hashtags;
@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN in_reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+ALTER TABLE chirps ADD COLUMN conversation_id UUID;
+UPDATE chirps SET conversation_id = id;
+ALTER TABLE chirps ALTER COLUMN conversation_id SET NOT NULL;
+
+CREATE INDEX chirps_in_reply_to_id_idx ON chirps(in_reply_to_id);
+CREATE INDEX chirps_conversation_id_idx ON chirps(conversation_id);
+
+-- +goose Down
+DROP INDEX chirps_conversation_id_idx;
+DROP INDEX chirps_in_reply_to_id_idx;
+ALTER TABLE chirps DROP COLUMN conversation_id;
+ALTER TABLE chirps DROP COLUMN in_reply_to_id;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- a rechirp has no body of its own and is removed with the original, a quote keeps its body and loses the link
+ALTER TABLE chirps ADD COLUMN rechirp_of_id UUID REFERENCES chirps(id) ON DELETE CASCADE;
+ALTER TABLE chirps ADD COLUMN quote_of_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+ALTER TABLE chirps ADD COLUMN is_quote BOOLEAN NOT NULL DEFAULT false;
+
+CREATE UNIQUE INDEX chirps_user_rechirp_idx ON chirps(user_id, rechirp_of_id) WHERE rechirp_of_id IS NOT NULL;
+CREATE INDEX chirps_quote_of_id_idx ON chirps(quote_of_id);
+
+-- +goose Down
+DROP INDEX chirps_quote_of_id_idx;
+DROP INDEX chirps_user_rechirp_idx;
+ALTER TABLE chirps DROP COLUMN is_quote;
+ALTER TABLE chirps DROP COLUMN quote_of_id;
+ALTER TABLE chirps DROP COLUMN rechirp_of_id;
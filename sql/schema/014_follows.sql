@@ -0,0 +1,58 @@
+-- +goose Up
+CREATE TABLE follows(
+follower_id UUID NOT NULL,
+followee_id UUID NOT NULL,
+created_at TIMESTAMP NOT NULL,
+PRIMARY KEY (follower_id, followee_id),
+FOREIGN KEY (follower_id) REFERENCES users(id) ON DELETE CASCADE,
+FOREIGN KEY (followee_id) REFERENCES users(id) ON DELETE CASCADE,
+CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX follows_followee_page_idx ON follows(followee_id, created_at DESC, follower_id DESC);
+CREATE INDEX follows_follower_page_idx ON follows(follower_id, created_at DESC, followee_id DESC);
+
+-- home timelines are materialized when a chirp is posted (fan-out-on-write) so reading one is a single index range scan
+-- no matter how many accounts the reader follows
+CREATE TABLE timeline_entries(
+user_id UUID NOT NULL,
+chirp_id UUID NOT NULL,
+author_id UUID NOT NULL,
+created_at TIMESTAMP NOT NULL,
+PRIMARY KEY (user_id, chirp_id),
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX timeline_entries_page_idx ON timeline_entries(user_id, created_at DESC, chirp_id DESC);
+CREATE INDEX timeline_entries_author_idx ON timeline_entries(user_id, author_id);
+
+-- +goose StatementBegin
+CREATE FUNCTION fan_out_chirp() RETURNS trigger AS $$
+BEGIN
+	IF NEW.user_id IS NULL THEN
+		RETURN NULL;
+	END IF;
+	INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+	SELECT follows.follower_id, NEW.id, NEW.user_id, NEW.created_at FROM follows
+	WHERE follows.followee_id = NEW.user_id
+	UNION ALL
+	SELECT NEW.user_id, NEW.id, NEW.user_id, NEW.created_at;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_fan_out_trigger
+AFTER INSERT ON chirps
+FOR EACH ROW EXECUTE FUNCTION fan_out_chirp();
+
+-- everyone's timeline starts with their own chirps
+INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+SELECT user_id, id, user_id, created_at FROM chirps WHERE user_id IS NOT NULL;
+
+-- +goose Down
+DROP TRIGGER chirps_fan_out_trigger ON chirps;
+DROP FUNCTION fan_out_chirp();
+DROP TABLE timeline_entries;
+DROP TABLE follows;
@@ -0,0 +1,111 @@
+-- +goose Up
+CREATE TABLE notifications(
+id UUID PRIMARY KEY,
+user_id UUID NOT NULL,
+actor_id UUID NOT NULL,
+type TEXT NOT NULL,
+chirp_id UUID,
+created_at TIMESTAMP NOT NULL,
+read_at TIMESTAMP,
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE CASCADE,
+FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX notifications_page_idx ON notifications(user_id, created_at DESC, id DESC);
+CREATE INDEX notifications_unread_idx ON notifications(user_id) WHERE read_at IS NULL;
+
+CREATE TABLE notification_mutes(
+user_id UUID NOT NULL,
+type TEXT NOT NULL,
+PRIMARY KEY (user_id, type),
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- notifications are written by triggers on the tables that record each event, so every code path that likes,
+-- follows, replies or mentions gets them for free. Self notifications, muted types and repeats are dropped
+-- +goose StatementBegin
+CREATE FUNCTION create_notification(recipient UUID, actor UUID, notification_type TEXT, chirp UUID) RETURNS void AS $$
+BEGIN
+	IF recipient IS NULL OR actor IS NULL OR recipient = actor THEN
+		RETURN;
+	END IF;
+	IF EXISTS (SELECT 1 FROM notification_mutes WHERE user_id = recipient AND type = notification_type) THEN
+		RETURN;
+	END IF;
+	IF EXISTS (
+		SELECT 1 FROM notifications
+		WHERE user_id = recipient AND actor_id = actor AND type = notification_type AND chirp_id IS NOT DISTINCT FROM chirp
+	) THEN
+		RETURN;
+	END IF;
+	INSERT INTO notifications (id, user_id, actor_id, type, chirp_id, created_at)
+	VALUES (gen_random_uuid(), recipient, actor, notification_type, chirp, NOW());
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION notify_like() RETURNS trigger AS $$
+BEGIN
+	PERFORM create_notification((SELECT user_id FROM chirps WHERE id = NEW.chirp_id), NEW.user_id, 'like', NEW.chirp_id);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION notify_follow() RETURNS trigger AS $$
+BEGIN
+	PERFORM create_notification(NEW.followee_id, NEW.follower_id, 'follow', NULL);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION notify_reply() RETURNS trigger AS $$
+BEGIN
+	IF NEW.in_reply_to_id IS NOT NULL THEN
+		PERFORM create_notification((SELECT user_id FROM chirps WHERE id = NEW.in_reply_to_id), NEW.user_id, 'reply', NEW.id);
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION notify_mention() RETURNS trigger AS $$
+BEGIN
+	PERFORM create_notification(
+		(SELECT id FROM users WHERE lower(handle) = lower(NEW.handle) AND deleted_at IS NULL),
+		(SELECT user_id FROM chirps WHERE id = NEW.chirp_id),
+		'mention',
+		NEW.chirp_id
+	);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER likes_notify_trigger AFTER INSERT ON likes
+FOR EACH ROW EXECUTE FUNCTION notify_like();
+CREATE TRIGGER follows_notify_trigger AFTER INSERT ON follows
+FOR EACH ROW EXECUTE FUNCTION notify_follow();
+CREATE TRIGGER chirps_notify_reply_trigger AFTER INSERT ON chirps
+FOR EACH ROW EXECUTE FUNCTION notify_reply();
+CREATE TRIGGER chirp_mentions_notify_trigger AFTER INSERT ON chirp_mentions
+FOR EACH ROW EXECUTE FUNCTION notify_mention();
+
+-- +goose Down
+DROP TRIGGER chirp_mentions_notify_trigger ON chirp_mentions;
+DROP TRIGGER chirps_notify_reply_trigger ON chirps;
+DROP TRIGGER follows_notify_trigger ON follows;
+DROP TRIGGER likes_notify_trigger ON likes;
+DROP FUNCTION notify_mention();
+DROP FUNCTION notify_reply();
+DROP FUNCTION notify_follow();
+DROP FUNCTION notify_like();
+DROP FUNCTION create_notification(UUID, UUID, TEXT, UUID);
+DROP TABLE notification_mutes;
+DROP TABLE notifications;
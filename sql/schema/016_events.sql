@@ -0,0 +1,31 @@
+-- +goose Up
+-- outbox for real-time events, rows are written in the same transaction as the change they describe and every
+-- instance LISTENs for the ids. The table is also where a listener that lost its connection catches up from
+CREATE TABLE events(
+id BIGSERIAL PRIMARY KEY,
+type TEXT NOT NULL,
+topics TEXT[] NOT NULL,
+data JSONB NOT NULL,
+created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX events_created_at_idx ON events(created_at);
+
+-- the payload is just the id, pg_notify payloads are capped at 8000 bytes
+-- +goose StatementBegin
+CREATE FUNCTION notify_event() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify('chirpy_events', NEW.id::text);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER events_notify_trigger
+AFTER INSERT ON events
+FOR EACH ROW EXECUTE FUNCTION notify_event();
+
+-- +goose Down
+DROP TRIGGER events_notify_trigger ON events;
+DROP FUNCTION notify_event();
+DROP TABLE events;
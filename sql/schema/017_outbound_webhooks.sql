@@ -0,0 +1,50 @@
+-- +goose Up
+-- endpoints with a NULL user_id were registered with the admin API key and get every event,
+-- user endpoints only get events about their own chirps
+CREATE TABLE webhook_endpoints(
+id UUID PRIMARY KEY,
+user_id UUID,
+url TEXT NOT NULL,
+secret TEXT NOT NULL,
+event_types TEXT[] NOT NULL,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_endpoints_user_idx ON webhook_endpoints(user_id);
+
+-- the delivery queue, status is pending, delivered or dead. next_attempt_at doubles as a lease while a worker sends it
+CREATE TABLE webhook_deliveries(
+id UUID PRIMARY KEY,
+endpoint_id UUID NOT NULL,
+event_id UUID NOT NULL,
+event_type TEXT NOT NULL,
+payload JSONB NOT NULL,
+status TEXT NOT NULL,
+attempts INTEGER NOT NULL,
+next_attempt_at TIMESTAMP,
+last_status_code INTEGER,
+last_error TEXT,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+delivered_at TIMESTAMP,
+FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoints(id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- deliveries that ran out of retries, with the endpoint they were headed for
+CREATE VIEW webhook_dead_letters AS
+SELECT webhook_deliveries.id, webhook_deliveries.endpoint_id, webhook_endpoints.user_id, webhook_endpoints.url,
+	webhook_deliveries.event_id, webhook_deliveries.event_type, webhook_deliveries.attempts,
+	webhook_deliveries.last_status_code, webhook_deliveries.last_error, webhook_deliveries.created_at,
+	webhook_deliveries.updated_at
+FROM webhook_deliveries
+JOIN webhook_endpoints ON webhook_endpoints.id = webhook_deliveries.endpoint_id
+WHERE webhook_deliveries.status = 'dead';
+
+-- +goose Down
+DROP VIEW webhook_dead_letters;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
@@ -0,0 +1,28 @@
+-- +goose Up
+-- uploaded images, the blobs themselves live in media storage under storage_key and thumbnail_key
+CREATE TABLE media(
+id UUID PRIMARY KEY,
+user_id UUID NOT NULL,
+content_type TEXT NOT NULL,
+width INTEGER NOT NULL,
+height INTEGER NOT NULL,
+size_bytes INTEGER NOT NULL,
+storage_key TEXT NOT NULL,
+thumbnail_key TEXT NOT NULL,
+created_at TIMESTAMP NOT NULL,
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- a media item is attached to at most one chirp, position orders a chirp's attachments
+CREATE TABLE chirp_media(
+chirp_id UUID NOT NULL,
+media_id UUID NOT NULL UNIQUE,
+position INTEGER NOT NULL,
+PRIMARY KEY (chirp_id, position),
+FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE,
+FOREIGN KEY (media_id) REFERENCES media(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE chirp_media;
+DROP TABLE media;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- one cached preview per URL shared by every chirp that links it. status is pending, ok or failed,
+-- next_fetch_at is when a pending preview is due and doubles as a lease while a worker fetches it
+CREATE TABLE link_previews(
+url TEXT PRIMARY KEY,
+status TEXT NOT NULL,
+title TEXT NOT NULL DEFAULT '',
+description TEXT NOT NULL DEFAULT '',
+image_url TEXT NOT NULL DEFAULT '',
+site_name TEXT NOT NULL DEFAULT '',
+attempts INTEGER NOT NULL DEFAULT 0,
+last_error TEXT,
+next_fetch_at TIMESTAMP,
+fetched_at TIMESTAMP,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX link_previews_due_idx ON link_previews(next_fetch_at) WHERE status = 'pending';
+
+CREATE TABLE chirp_links(
+chirp_id UUID NOT NULL,
+url TEXT NOT NULL,
+start_offset INTEGER NOT NULL,
+end_offset INTEGER NOT NULL,
+PRIMARY KEY (chirp_id, start_offset),
+FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE,
+FOREIGN KEY (url) REFERENCES link_previews(url) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE chirp_links;
+DROP TABLE link_previews;
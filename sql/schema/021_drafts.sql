@@ -0,0 +1,21 @@
+-- +goose Up
+-- unpublished chirps only their author can see. a draft with publish_at set is scheduled, the scheduler turns it into a
+-- chirp once publish_at passes and deletes it in the same transaction. last_error says why a scheduled draft couldn't be
+-- published, it's unscheduled again when that happens
+CREATE TABLE drafts(
+id UUID PRIMARY KEY,
+user_id UUID NOT NULL,
+body TEXT NOT NULL,
+media_ids UUID[] NOT NULL DEFAULT '{}',
+publish_at TIMESTAMP,
+last_error TEXT,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX drafts_user_id_idx ON drafts(user_id, updated_at DESC);
+CREATE INDEX drafts_publish_at_idx ON drafts(publish_at) WHERE publish_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE drafts;
@@ -0,0 +1,57 @@
+-- +goose Up
+-- a chirp carries at most one poll, keyed by the chirp. votes reference the option together with its chirp so a vote
+-- can only be for one of that poll's options, and the primary key allows one vote per user per poll
+CREATE TABLE polls(
+chirp_id UUID PRIMARY KEY,
+closes_at TIMESTAMP NOT NULL,
+created_at TIMESTAMP NOT NULL,
+FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE TABLE poll_options(
+id UUID PRIMARY KEY,
+chirp_id UUID NOT NULL,
+position INTEGER NOT NULL,
+label TEXT NOT NULL,
+vote_count INTEGER NOT NULL DEFAULT 0,
+UNIQUE (chirp_id, position),
+UNIQUE (chirp_id, id),
+FOREIGN KEY (chirp_id) REFERENCES polls(chirp_id) ON DELETE CASCADE
+);
+
+CREATE TABLE poll_votes(
+chirp_id UUID NOT NULL,
+user_id UUID NOT NULL,
+option_id UUID NOT NULL,
+created_at TIMESTAMP NOT NULL,
+PRIMARY KEY (chirp_id, user_id),
+FOREIGN KEY (chirp_id, option_id) REFERENCES poll_options(chirp_id, id) ON DELETE CASCADE,
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX poll_votes_user_id_idx ON poll_votes(user_id);
+
+-- tallies are kept by a trigger like like_count, so votes removed when an account is purged are taken off too
+-- +goose StatementBegin
+CREATE FUNCTION update_poll_vote_count() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'INSERT' THEN
+		UPDATE poll_options SET vote_count = vote_count + 1 WHERE id = NEW.option_id;
+	ELSE
+		UPDATE poll_options SET vote_count = vote_count - 1 WHERE id = OLD.option_id;
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER poll_votes_count_trigger
+AFTER INSERT OR DELETE ON poll_votes
+FOR EACH ROW EXECUTE FUNCTION update_poll_vote_count();
+
+-- +goose Down
+DROP TRIGGER poll_votes_count_trigger ON poll_votes;
+DROP FUNCTION update_poll_vote_count();
+DROP TABLE poll_votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
@@ -0,0 +1,42 @@
+-- +goose Up
+-- bookmarks are private, only the user who saved a chirp can see that they did
+CREATE TABLE bookmarks(
+user_id UUID NOT NULL,
+chirp_id UUID NOT NULL,
+created_at TIMESTAMP NOT NULL,
+PRIMARY KEY (user_id, chirp_id),
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX bookmarks_page_idx ON bookmarks(user_id, created_at DESC, chirp_id DESC);
+
+-- named groups of users kept by their owner, a list's feed is read from chirps at request time rather than fanned out
+-- like home timelines, so adding someone to a list shows their older chirps too
+CREATE TABLE lists(
+id UUID PRIMARY KEY,
+user_id UUID NOT NULL,
+name TEXT NOT NULL,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL,
+UNIQUE (user_id, name),
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE list_members(
+list_id UUID NOT NULL,
+user_id UUID NOT NULL,
+created_at TIMESTAMP NOT NULL,
+PRIMARY KEY (list_id, user_id),
+FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE,
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX list_members_user_id_idx ON list_members(user_id);
+CREATE INDEX chirps_user_id_page_idx ON chirps(user_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX chirps_user_id_page_idx;
+DROP TABLE list_members;
+DROP TABLE lists;
+DROP TABLE bookmarks;
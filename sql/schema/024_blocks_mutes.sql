@@ -0,0 +1,84 @@
+-- +goose Up
+-- a block hides the two users from each other and stops them interacting, a mute only hides the muted user from the
+-- muter's timelines and notifications and the muted user can't tell
+CREATE TABLE blocks(
+blocker_id UUID NOT NULL,
+blocked_id UUID NOT NULL,
+created_at TIMESTAMP NOT NULL,
+PRIMARY KEY (blocker_id, blocked_id),
+FOREIGN KEY (blocker_id) REFERENCES users(id) ON DELETE CASCADE,
+FOREIGN KEY (blocked_id) REFERENCES users(id) ON DELETE CASCADE,
+CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX blocks_blocked_id_idx ON blocks(blocked_id);
+
+CREATE TABLE user_mutes(
+muter_id UUID NOT NULL,
+muted_id UUID NOT NULL,
+created_at TIMESTAMP NOT NULL,
+PRIMARY KEY (muter_id, muted_id),
+FOREIGN KEY (muter_id) REFERENCES users(id) ON DELETE CASCADE,
+FOREIGN KEY (muted_id) REFERENCES users(id) ON DELETE CASCADE,
+CHECK (muter_id <> muted_id)
+);
+
+-- every user hidden from viewer_id, blocks count in both directions. blocked is false for mutes, which only apply to
+-- timelines and notifications
+CREATE VIEW hidden_users AS
+SELECT blocker_id AS viewer_id, blocked_id AS user_id, TRUE AS blocked FROM blocks
+UNION ALL
+SELECT blocked_id AS viewer_id, blocker_id AS user_id, TRUE AS blocked FROM blocks
+UNION ALL
+SELECT muter_id AS viewer_id, muted_id AS user_id, FALSE AS blocked FROM user_mutes;
+
+-- same as before with hidden users dropped, so blocked users can't reach each other through likes, follows, replies
+-- or mentions and muted users don't notify the muter
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION create_notification(recipient UUID, actor UUID, notification_type TEXT, chirp UUID) RETURNS void AS $$
+BEGIN
+	IF recipient IS NULL OR actor IS NULL OR recipient = actor THEN
+		RETURN;
+	END IF;
+	IF EXISTS (SELECT 1 FROM notification_mutes WHERE user_id = recipient AND type = notification_type) THEN
+		RETURN;
+	END IF;
+	IF EXISTS (SELECT 1 FROM hidden_users WHERE viewer_id = recipient AND user_id = actor) THEN
+		RETURN;
+	END IF;
+	IF EXISTS (
+		SELECT 1 FROM notifications
+		WHERE user_id = recipient AND actor_id = actor AND type = notification_type AND chirp_id IS NOT DISTINCT FROM chirp
+	) THEN
+		RETURN;
+	END IF;
+	INSERT INTO notifications (id, user_id, actor_id, type, chirp_id, created_at)
+	VALUES (gen_random_uuid(), recipient, actor, notification_type, chirp, NOW());
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION create_notification(recipient UUID, actor UUID, notification_type TEXT, chirp UUID) RETURNS void AS $$
+BEGIN
+	IF recipient IS NULL OR actor IS NULL OR recipient = actor THEN
+		RETURN;
+	END IF;
+	IF EXISTS (SELECT 1 FROM notification_mutes WHERE user_id = recipient AND type = notification_type) THEN
+		RETURN;
+	END IF;
+	IF EXISTS (
+		SELECT 1 FROM notifications
+		WHERE user_id = recipient AND actor_id = actor AND type = notification_type AND chirp_id IS NOT DISTINCT FROM chirp
+	) THEN
+		RETURN;
+	END IF;
+	INSERT INTO notifications (id, user_id, actor_id, type, chirp_id, created_at)
+	VALUES (gen_random_uuid(), recipient, actor, notification_type, chirp, NOW());
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+DROP VIEW hidden_users;
+DROP TABLE user_mutes;
+DROP TABLE blocks;
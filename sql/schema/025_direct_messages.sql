@@ -0,0 +1,39 @@
+-- +goose Up
+-- private conversations between two or more users, kept apart from chirps so messages never reach feeds, search,
+-- hashtags or webhooks. direct_key is set on one-to-one conversations so each pair of users shares a single one
+CREATE TABLE conversations(
+id UUID PRIMARY KEY,
+direct_key TEXT UNIQUE,
+created_at TIMESTAMP NOT NULL,
+last_message_at TIMESTAMP NOT NULL
+);
+
+-- last_read_at is null until the member first marks the conversation read
+CREATE TABLE conversation_members(
+conversation_id UUID NOT NULL,
+user_id UUID NOT NULL,
+joined_at TIMESTAMP NOT NULL,
+last_read_at TIMESTAMP,
+PRIMARY KEY (conversation_id, user_id),
+FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX conversation_members_user_id_idx ON conversation_members(user_id);
+
+CREATE TABLE messages(
+id UUID PRIMARY KEY,
+conversation_id UUID NOT NULL,
+sender_id UUID NOT NULL,
+body TEXT NOT NULL,
+created_at TIMESTAMP NOT NULL,
+FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX messages_page_idx ON messages(conversation_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP TABLE messages;
+DROP TABLE conversation_members;
+DROP TABLE conversations;
@@ -0,0 +1,64 @@
+-- +goose Up
+-- users who can work the report queue, the admin API key can too and also decides who's in this table
+CREATE TABLE moderators(
+user_id UUID PRIMARY KEY,
+created_at TIMESTAMP NOT NULL,
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- user_id is the reported user, for chirp reports that's the chirp's author. chirp_body keeps what was reported
+-- so the report still makes sense after the chirp is edited or removed
+CREATE TABLE reports(
+id UUID PRIMARY KEY,
+reporter_id UUID NOT NULL,
+user_id UUID NOT NULL,
+chirp_id UUID,
+chirp_body TEXT,
+reason TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'impersonation', 'other')),
+details TEXT NOT NULL,
+status TEXT NOT NULL CHECK (status IN ('open', 'dismissed', 'resolved')),
+created_at TIMESTAMP NOT NULL,
+resolved_at TIMESTAMP,
+FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE SET NULL
+);
+
+CREATE INDEX reports_queue_idx ON reports(status, created_at, id);
+CREATE INDEX reports_user_id_idx ON reports(user_id);
+
+-- the audit trail, rows are only ever inserted. moderator_id is null for actions taken with the admin API key and
+-- ids aren't foreign keys so the trail outlives the chirps, reports and users it mentions
+CREATE TABLE moderation_actions(
+id UUID PRIMARY KEY,
+moderator_id UUID,
+action TEXT NOT NULL,
+report_id UUID,
+user_id UUID,
+chirp_id UUID,
+note TEXT NOT NULL,
+created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX moderation_actions_page_idx ON moderation_actions(created_at DESC, id DESC);
+
+-- suspended_until is null for a suspension with no end
+CREATE TABLE user_suspensions(
+user_id UUID PRIMARY KEY,
+suspended_at TIMESTAMP NOT NULL,
+suspended_until TIMESTAMP,
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- moderation notifications come from the service rather than another user, so they have no actor
+ALTER TABLE notifications ALTER COLUMN actor_id DROP NOT NULL;
+ALTER TABLE notifications ADD COLUMN report_id UUID REFERENCES reports(id) ON DELETE CASCADE;
+
+-- +goose Down
+DELETE FROM notifications WHERE actor_id IS NULL;
+ALTER TABLE notifications DROP COLUMN report_id;
+ALTER TABLE notifications ALTER COLUMN actor_id SET NOT NULL;
+DROP TABLE user_suspensions;
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+DROP TABLE moderators;
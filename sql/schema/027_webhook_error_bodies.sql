@@ -0,0 +1,8 @@
+-- +goose Up
+-- failed deliveries used to keep part of the endpoint's response body in last_error, which the endpoint's owner can
+-- read back. Only the status is kept now, this covers dead letters too since webhook_dead_letters is a view over these rows
+UPDATE webhook_deliveries SET last_error = substring(last_error FROM '^endpoint responded [0-9]+')
+WHERE last_error ~ '^endpoint responded [0-9]+: ';
+
+-- +goose Down
+-- the bodies are gone, there's nothing to put back
@@ -0,0 +1,6 @@
+-- +goose Up
+-- set when the login was started to restore an account that's pending deletion
+ALTER TABLE oidc_login_states ADD COLUMN restore BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE oidc_login_states DROP COLUMN restore;
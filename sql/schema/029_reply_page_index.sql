@@ -0,0 +1,8 @@
+-- +goose Up
+-- replies are read oldest first under their parent, both for the top level page and the replies under each node
+CREATE INDEX chirps_reply_page_idx ON chirps(in_reply_to_id, created_at, id);
+DROP INDEX chirps_in_reply_to_id_idx;
+
+-- +goose Down
+CREATE INDEX chirps_in_reply_to_id_idx ON chirps(in_reply_to_id);
+DROP INDEX chirps_reply_page_idx;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- emails were never unique, so a lookup by email could land on any of several accounts. Accounts sharing an email
+-- (ignoring case) have to be merged by hand before this runs
+CREATE UNIQUE INDEX users_email_lower_idx ON users(lower(email));
+
+-- +goose Down
+DROP INDEX users_email_lower_idx;
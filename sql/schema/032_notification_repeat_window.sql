@@ -0,0 +1,59 @@
+-- +goose Up
+-- a repeat folds into an unread notification of any age, and into a read one from the last day. Unliking and liking
+-- again, or unfollowing and following again, can't notify more than once a day, while a repeat after that still does.
+-- The check now looks at read rows too, so the index covers all of them
+DROP INDEX notifications_dedup_idx;
+CREATE INDEX notifications_dedup_idx ON notifications(user_id, actor_id, type, chirp_id, created_at);
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION create_notification(recipient UUID, actor UUID, notification_type TEXT, chirp UUID) RETURNS void AS $$
+BEGIN
+	IF recipient IS NULL OR actor IS NULL OR recipient = actor THEN
+		RETURN;
+	END IF;
+	IF EXISTS (SELECT 1 FROM notification_mutes WHERE user_id = recipient AND type = notification_type) THEN
+		RETURN;
+	END IF;
+	IF EXISTS (SELECT 1 FROM hidden_users WHERE viewer_id = recipient AND user_id = actor) THEN
+		RETURN;
+	END IF;
+	IF EXISTS (
+		SELECT 1 FROM notifications
+		WHERE user_id = recipient AND actor_id = actor AND type = notification_type AND chirp_id IS NOT DISTINCT FROM chirp
+			AND (read_at IS NULL OR created_at > NOW() - INTERVAL '1 day')
+	) THEN
+		RETURN;
+	END IF;
+	INSERT INTO notifications (id, user_id, actor_id, type, chirp_id, created_at)
+	VALUES (gen_random_uuid(), recipient, actor, notification_type, chirp, NOW());
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION create_notification(recipient UUID, actor UUID, notification_type TEXT, chirp UUID) RETURNS void AS $$
+BEGIN
+	IF recipient IS NULL OR actor IS NULL OR recipient = actor THEN
+		RETURN;
+	END IF;
+	IF EXISTS (SELECT 1 FROM notification_mutes WHERE user_id = recipient AND type = notification_type) THEN
+		RETURN;
+	END IF;
+	IF EXISTS (SELECT 1 FROM hidden_users WHERE viewer_id = recipient AND user_id = actor) THEN
+		RETURN;
+	END IF;
+	IF EXISTS (
+		SELECT 1 FROM notifications
+		WHERE user_id = recipient AND actor_id = actor AND type = notification_type AND chirp_id IS NOT DISTINCT FROM chirp
+			AND read_at IS NULL
+	) THEN
+		RETURN;
+	END IF;
+	INSERT INTO notifications (id, user_id, actor_id, type, chirp_id, created_at)
+	VALUES (gen_random_uuid(), recipient, actor, notification_type, chirp, NOW());
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+DROP INDEX notifications_dedup_idx;
+CREATE INDEX notifications_dedup_idx ON notifications(user_id, actor_id, type, chirp_id) WHERE read_at IS NULL;
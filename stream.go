@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/broker"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const sseKeepaliveInterval = 15 * time.Second
+
+// events kept for Last-Event-ID resumption and how far a subscriber can fall behind before it's dropped
+const eventReplaySize = 1000
+const eventSubscriberBuffer = 64
+
+// Streams newly created chirps as server-sent events. ?author_id= and ?hashtag= (both repeatable) narrow the stream
+// to chirps matching any of them, without filters every new chirp is sent. Reconnecting clients send Last-Event-ID
+// (or ?last_event_id= where the client can't set headers) and get the buffered events they missed, a "gap" event
+// tells them the buffer didn't reach back far enough and they should refetch GET /api/chirps
+func (cfg *apiConfig) streamChirps(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		fmt.Printf("Streaming unsupported by response writer\n")
+		w.WriteHeader(500)
+		return
+	}
+
+	topics := []string{}
+	for _, author := range r.URL.Query()["author_id"] {
+		authorID, err := uuid.Parse(author)
+		if err != nil {
+			rtn := &returnErrors{Error: "Invalid author_id"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal stream author error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+		topics = append(topics, authorTopic(authorID))
+	}
+	for _, tag := range r.URL.Query()["hashtag"] {
+		topics = append(topics, hashtagTopic(tag))
+	}
+	if len(topics) == 0 {
+		topics = append(topics, "chirps")
+	}
+
+	lastEventIDStr := r.Header.Get("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = r.URL.Query().Get("last_event_id")
+	}
+	//an unreadable ID is treated like a fresh connection
+	lastEventID, _ := strconv.ParseUint(lastEventIDStr, 10, 64)
+
+	sub, replay, complete := cfg.events.Subscribe(topics, lastEventID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if !complete {
+		fmt.Fprint(w, "event: gap\ndata: {}\n\n")
+	}
+	for _, ev := range replay {
+		if writeSSE(w, ev) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(sseKeepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-sub.C:
+			//a closed channel means the broker dropped us for falling behind, the client reconnects and resumes
+			if !ok {
+				return
+			}
+			if writeSSE(w, ev) != nil {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeSSE(w http.ResponseWriter, ev broker.Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Data)
+	return err
+}
+
+// Publishes a newly created chirp to the event broker, viewer specific fields are left out since everyone gets the same event.
+// Besides the global, author and hashtag topics it goes to the timeline topic of the author and each of their followers
+func (cfg *apiConfig) publishChirp(ctx context.Context, ch Chirp) {
+	ch.LikedByMe = nil
+	followerIDs, err := cfg.database.GetFollowerIDs(ctx, ch.UserID)
+	if err != nil {
+		fmt.Printf("Error querying followers for chirp event: %s\n", err)
+	}
+	topics := []string{"chirps", authorTopic(ch.UserID), timelineTopic(ch.UserID)}
+	for _, tag := range ch.Entities.Hashtags {
+		topics = append(topics, hashtagTopic(tag.Tag))
+	}
+	for _, followerID := range followerIDs {
+		topics = append(topics, timelineTopic(followerID))
+	}
+	err = publishEvent(ctx, cfg.database, "chirp", topics, ch)
+	if err != nil {
+		fmt.Printf("Error publishing chirp event: %s\n", err)
+	}
+}
+
+func authorTopic(userID uuid.UUID) string {
+	return "author:" + userID.String()
+}
+
+func timelineTopic(userID uuid.UUID) string {
+	return "timeline:" + userID.String()
+}
+
+// tags are stored lowercase without the #
+func hashtagTopic(tag string) string {
+	return "hashtag:" + strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"github.com/statusquonjc46/chirpy-http/internal/pagination"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+const defaultThreadDepth = 3
+const maxThreadDepth = 5
+
+// below the top level each chirp shows its oldest replies up to this many, and a whole thread at most maxThreadNodes
+const threadRepliesPerNode = 5
+const maxThreadNodes = 500
+
+// NextCursor is set when only some of the chirp's replies are shown, GET /api/chirps/{id}/thread?cursor= continues them
+type ThreadNode struct {
+	Chirp      Chirp        `json:"chirp"`
+	ReplyCount int64        `json:"reply_count"`
+	Replies    []ThreadNode `json:"replies"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// Returns a chirp with its ancestors (root first) and a depth limited tree of replies, top level replies are paginated
+// oldest first and deeper ones are cut to threadRepliesPerNode with a cursor on the node to continue them
+func (cfg *apiConfig) getChirpThread(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Chirp      Chirp        `json:"chirp"`
+		Ancestors  []Chirp      `json:"ancestors"`
+		Replies    []ThreadNode `json:"replies"`
+		NextCursor string       `json:"next_cursor,omitempty"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		rtn := &returnErrors{Error: "Invalid chirp ID"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal thread chirp ID error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	viewerID, _ := cfg.userIDFromRequest(r)
+	chirp, err := cfg.visibleChirp(r.Context(), chirpID, viewerID)
+	if err != nil {
+		status := 503
+		rtn := &returnErrors{Error: "Failed to query DB for chirpID"}
+		if errors.Is(err, sql.ErrNoRows) {
+			status = 404
+			rtn.Error = "Chirp not found"
+		}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal thread chirp lookup error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(dat)
+		return
+	}
+
+	depth := defaultThreadDepth
+	if depthStr := r.URL.Query().Get("depth"); depthStr != "" {
+		depth, err = strconv.Atoi(depthStr)
+		if err != nil || depth < 1 {
+			rtn := &returnErrors{Error: "depth must be a positive number"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal thread depth error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+		depth = min(depth, maxThreadDepth)
+	}
+
+	limit, err := pageLimit(r, 20, 100)
+	if err != nil {
+		rtn := &returnErrors{Error: "limit must be a positive number"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal thread limit error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	treeParams := database.GetReplyTreeParams{
+		ChirpID:    uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		PageLimit:  limit,
+		ChildLimit: threadRepliesPerNode,
+		MaxDepth:   int32(depth),
+		NodeLimit:  maxThreadNodes,
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := pagination.DecodeCursor(cursor)
+		if err != nil {
+			rtn := &returnErrors{Error: "Invalid cursor"}
+			dat, err := json.Marshal(rtn)
+			if err != nil {
+				fmt.Printf("Failed to marshal thread cursor error: %s\n", err)
+				w.WriteHeader(500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(400)
+			w.Write(dat)
+			return
+		}
+		treeParams.CursorCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		treeParams.CursorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	ancestorRows, err := cfg.database.GetChirpAncestorIDs(r.Context(), chirp.ID)
+	if err != nil {
+		fmt.Printf("Error querying chirp ancestors: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	treeRows, err := cfg.database.GetReplyTree(r.Context(), treeParams)
+	if err != nil {
+		fmt.Printf("Error querying reply tree: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+
+	//fetch every chirp in the thread in one query, then stitch the tree together in memory
+	ids := []uuid.UUID{chirp.ID}
+	for _, a := range ancestorRows {
+		ids = append(ids, a.ID)
+	}
+	children := make(map[uuid.UUID][]uuid.UUID)
+	lastChild := make(map[uuid.UUID]database.GetReplyTreeRow)
+	treeIDs := make([]uuid.UUID, 0, len(treeRows))
+	for _, t := range treeRows {
+		ids = append(ids, t.ID)
+		treeIDs = append(treeIDs, t.ID)
+		children[t.InReplyToID.UUID] = append(children[t.InReplyToID.UUID], t.ID)
+		//rows come back oldest first under each parent
+		lastChild[t.InReplyToID.UUID] = t
+	}
+
+	rows, err := cfg.database.GetChirpsByIDs(r.Context(), ids)
+	if err != nil {
+		fmt.Printf("Error querying thread chirps: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	chirps, err := cfg.chirpResponses(r.Context(), rows, viewerID)
+	if err != nil {
+		fmt.Printf("Error building thread chirps: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	byID := make(map[uuid.UUID]Chirp, len(chirps))
+	for _, ch := range chirps {
+		byID[ch.ID] = ch
+	}
+
+	countRows, err := cfg.database.GetReplyCounts(r.Context(), treeIDs)
+	if err != nil {
+		fmt.Printf("Error querying reply counts: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	replyCounts := make(map[uuid.UUID]int64, len(countRows))
+	for _, c := range countRows {
+		replyCounts[c.InReplyToID.UUID] = c.ReplyCount
+	}
+
+	rtn := response{
+		Chirp:     byID[chirp.ID],
+		Ancestors: []Chirp{},
+		Replies:   buildThreadNodes(chirp.ID, children, lastChild, byID, replyCounts),
+	}
+	for _, a := range ancestorRows {
+		//ancestors by deleted accounts are left out of the chain
+		if ch, ok := byID[a.ID]; ok {
+			rtn.Ancestors = append(rtn.Ancestors, ch)
+		}
+	}
+	//a full page of top level replies means there may be more, the cursor points after the newest one returned
+	topLevelCount := 0
+	var last database.GetReplyTreeRow
+	for _, t := range treeRows {
+		if t.Depth != 1 {
+			continue
+		}
+		topLevelCount++
+		if t.CreatedAt.After(last.CreatedAt) || (t.CreatedAt.Equal(last.CreatedAt) && t.ID.String() > last.ID.String()) {
+			last = t
+		}
+	}
+	if topLevelCount == int(limit) {
+		rtn.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	dat, err := json.Marshal(rtn)
+	if err != nil {
+		fmt.Printf("Error marshalling thread: %s\n", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// Builds the reply nodes under parentID oldest first, replies whose chirp couldn't be loaded are dropped with their subtree.
+// A node with more replies than were loaded gets a cursor after the last one loaded
+func buildThreadNodes(parentID uuid.UUID, children map[uuid.UUID][]uuid.UUID, lastChild map[uuid.UUID]database.GetReplyTreeRow, byID map[uuid.UUID]Chirp, replyCounts map[uuid.UUID]int64) []ThreadNode {
+	nodes := []ThreadNode{}
+	for _, id := range children[parentID] {
+		ch, ok := byID[id]
+		if !ok {
+			continue
+		}
+		node := ThreadNode{
+			Chirp:      ch,
+			ReplyCount: replyCounts[id],
+			Replies:    buildThreadNodes(id, children, lastChild, byID, replyCounts),
+		}
+		if loaded := len(children[id]); loaded > 0 && int64(loaded) < node.ReplyCount {
+			last := lastChild[id]
+			node.NextCursor = pagination.EncodeCursor(last.CreatedAt, last.ID)
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Chirp.CreatedAt.Equal(nodes[j].Chirp.CreatedAt) {
+			return nodes[i].Chirp.ID.String() < nodes[j].Chirp.ID.String()
+		}
+		return nodes[i].Chirp.CreatedAt.Before(nodes[j].Chirp.CreatedAt)
+	})
+	return nodes
+}
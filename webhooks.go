@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/auth"
+	"github.com/statusquonjc46/chirpy-http/internal/database"
+	"io"
+	"net/http"
+)
+
+// Payment provider webhook, toggles Chirpy Red membership. Authenticated by API key or HMAC signature, idempotent per event ID
+func (cfg *apiConfig) paymentsWebhook(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ID    string `json:"id"`
+		Event string `json:"event"`
+		Data  struct {
+			UserID string `json:"user_id"`
+		} `json:"data"`
+	}
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	//the signature is over the exact bytes sent, so read the raw body before decoding
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		rtn := &returnErrors{Error: "Unable to read request body"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal webhook body error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	authenticated := false
+	if signature := r.Header.Get("X-Signature"); signature != "" {
+		authenticated = auth.VerifyHMACSignature(body, cfg.paymentsWebhookSecret, signature) == nil
+	} else if apiKey, err := auth.GetAPIKey(r.Header); err == nil {
+		authenticated = auth.SecretsMatch(apiKey, cfg.paymentsAPIKey)
+	}
+	if !authenticated {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal webhook auth error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(bytes.NewReader(body)).Decode(&params)
+	if err != nil || params.ID == "" {
+		rtn := &returnErrors{Error: "Invalid webhook payload"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal webhook payload error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	//only membership events are handled, anything else is acknowledged and ignored
+	var isChirpyRed bool
+	switch params.Event {
+	case "user.upgraded":
+		isChirpyRed = true
+	case "user.downgraded":
+		isChirpyRed = false
+	default:
+		w.WriteHeader(204)
+		return
+	}
+
+	userID, err := uuid.Parse(params.Data.UserID)
+	if err != nil {
+		rtn := &returnErrors{Error: "Invalid user id"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal webhook user id error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(dat)
+		return
+	}
+
+	//recording the event and applying it share a transaction, a redelivered event ID is a no-op
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		fmt.Printf("Failed to begin webhook transaction: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	recorded, err := qtx.RecordPaymentEvent(r.Context(), database.RecordPaymentEventParams{ID: params.ID, EventType: params.Event})
+	if err != nil {
+		fmt.Printf("Failed to record payment event: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	if recorded == 0 {
+		w.WriteHeader(204)
+		return
+	}
+
+	updated, err := qtx.SetChirpyRed(r.Context(), database.SetChirpyRedParams{ID: userID, IsChirpyRed: isChirpyRed})
+	if err != nil {
+		fmt.Printf("Failed to update chirpy red membership: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	if updated == 0 {
+		rtn := &returnErrors{Error: "User not found"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal webhook user not found error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(404)
+		w.Write(dat)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		fmt.Printf("Failed to commit payment event: %s\n", err)
+		w.WriteHeader(503)
+		return
+	}
+	w.WriteHeader(204)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/google/uuid"
+	"github.com/statusquonjc46/chirpy-http/internal/broker"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const wsMaxMessageSize = 4096
+const wsMaxTopics = 50
+const wsWriteTimeout = 10 * time.Second
+const wsPingInterval = 30 * time.Second
+
+// message sent by the client, type is subscribe or unsubscribe
+type wsClientMessage struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic"`
+}
+
+// message sent by the server, type is event, subscribed, unsubscribed or error
+type wsServerMessage struct {
+	Type  string          `json:"type"`
+	Topic string          `json:"topic,omitempty"`
+	Event string          `json:"event,omitempty"`
+	ID    uint64          `json:"id,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// Upgrades to a WebSocket for real-time delivery. The JWT comes in the Authorization header or, for browsers that
+// can't set headers on the upgrade, ?access_token=. Clients subscribe to "timeline", "notifications", "messages" and
+// "hashtag:<tag>", chirps by blocked users are never sent. Events are queued per connection, a client that falls too
+// far behind is disconnected with a policy violation
+func (cfg *apiConfig) websocketHandler(w http.ResponseWriter, r *http.Request) {
+	type returnErrors struct {
+		Error string `json:"error"`
+	}
+
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		rtn := &returnErrors{Error: "Unauthorized"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal unauthorized error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(401)
+		w.Write(dat)
+		return
+	}
+
+	blocked, err := cfg.blockedUserIDs(r.Context(), userID)
+	if err != nil {
+		rtn := &returnErrors{Error: "Failed to query DB for blocks"}
+		dat, err := json.Marshal(rtn)
+		if err != nil {
+			fmt.Printf("Failed to marshal websocket blocks error: %s\n", err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(503)
+		w.Write(dat)
+		return
+	}
+
+	//Accept writes its own error response when the handshake is bad
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		fmt.Printf("Error accepting websocket: %s\n", err)
+		return
+	}
+	cfg.wsConns.Add(1)
+	defer cfg.wsConns.Done()
+	defer conn.CloseNow()
+	conn.SetReadLimit(wsMaxMessageSize)
+
+	sub, _, _ := cfg.events.Subscribe(nil, 0)
+	defer sub.Close()
+
+	//ctx ends on server shutdown or when the reader stops
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	//cancelling a read closes the connection outright, so reads don't follow the server's context or the going away frame would never be sent
+	readCtx, cancelRead := context.WithCancel(context.Background())
+	defer cancelRead()
+
+	//the reader owns the subscription's topics, the writer below is the only goroutine writing to the connection
+	replies := make(chan wsServerMessage, 8)
+	go func() {
+		defer cancel()
+		topics := make(map[string]bool)
+		for {
+			var msg wsClientMessage
+			err := wsjson.Read(readCtx, conn, &msg)
+			if err != nil {
+				return
+			}
+			reply := handleWSMessage(userID, sub, topics, msg)
+			select {
+			case replies <- reply:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			if cfg.serverCtx.Err() != nil {
+				conn.Close(websocket.StatusGoingAway, "server shutting down")
+			}
+			return
+		case ev, ok := <-sub.C:
+			if !ok {
+				conn.Close(websocket.StatusPolicyViolation, "slow consumer")
+				return
+			}
+			if wsFromBlockedAuthor(ev, blocked) {
+				continue
+			}
+			err = writeWS(ctx, conn, wsEventMessage(sub, ev))
+		case reply := <-replies:
+			err = writeWS(ctx, conn, reply)
+		case <-ping.C:
+			//blocks made while connected apply from the next ping on, a failed refresh keeps the old set
+			if refreshed, err := cfg.blockedUserIDs(ctx, userID); err == nil {
+				blocked = refreshed
+			}
+			pingCtx, pingCancel := context.WithTimeout(ctx, wsWriteTimeout)
+			err = conn.Ping(pingCtx)
+			pingCancel()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func writeWS(ctx context.Context, conn *websocket.Conn, msg wsServerMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+	defer cancel()
+	return wsjson.Write(ctx, conn, msg)
+}
+
+// Applies a subscribe or unsubscribe request, topics tracks the client topic names this connection has subscribed
+func handleWSMessage(userID uuid.UUID, sub *broker.Subscription, topics map[string]bool, msg wsClientMessage) wsServerMessage {
+	brokerTopic, err := wsBrokerTopic(userID, msg.Topic)
+	if err != nil {
+		return wsServerMessage{Type: "error", Topic: msg.Topic, Error: err.Error()}
+	}
+
+	switch msg.Type {
+	case "subscribe":
+		if !topics[msg.Topic] && len(topics) >= wsMaxTopics {
+			return wsServerMessage{Type: "error", Topic: msg.Topic, Error: "too many subscriptions"}
+		}
+		topics[msg.Topic] = true
+		sub.Add(brokerTopic)
+		return wsServerMessage{Type: "subscribed", Topic: msg.Topic}
+	case "unsubscribe":
+		delete(topics, msg.Topic)
+		sub.Remove(brokerTopic)
+		return wsServerMessage{Type: "unsubscribed", Topic: msg.Topic}
+	}
+	return wsServerMessage{Type: "error", Error: "type must be subscribe or unsubscribe"}
+}
+
+// maps the topic names clients use onto broker topics, timeline, notifications and messages are always the caller's own
+func wsBrokerTopic(userID uuid.UUID, topic string) (string, error) {
+	if topic == "timeline" {
+		return timelineTopic(userID), nil
+	}
+	if topic == "notifications" {
+		return notificationsTopic(userID), nil
+	}
+	if topic == "messages" {
+		return messagesTopic(userID), nil
+	}
+	if tag, ok := strings.CutPrefix(topic, "hashtag:"); ok && tag != "" {
+		return hashtagTopic(tag), nil
+	}
+	return "", errors.New("unknown topic, use timeline, notifications, messages or hashtag:<tag>")
+}
+
+// hashtag topics are shared by everyone, so chirps by users blocked in either direction are dropped per connection
+func wsFromBlockedAuthor(ev broker.Event, blocked map[uuid.UUID]bool) bool {
+	if ev.Type != "chirp" || len(blocked) == 0 {
+		return false
+	}
+	var ch struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+	if json.Unmarshal(ev.Data, &ch) != nil {
+		return false
+	}
+	return blocked[ch.UserID]
+}
+
+// names the event after the first of its topics the connection is subscribed to
+func wsEventMessage(sub *broker.Subscription, ev broker.Event) wsServerMessage {
+	msg := wsServerMessage{Type: "event", Event: ev.Type, ID: ev.ID, Data: ev.Data}
+	for _, topic := range ev.Topics {
+		if !sub.Subscribed(topic) {
+			continue
+		}
+		msg.Topic = topic
+		if kind, _, _ := strings.Cut(topic, ":"); kind == "timeline" || kind == "notifications" || kind == "messages" {
+			msg.Topic = kind
+		}
+		break
+	}
+	return msg
+}